@@ -7,13 +7,16 @@
 package app
 
 import (
+	"context"
 	"os"
 
+	"github.com/easysoft/qcadmin/cmd/flags"
 	"github.com/easysoft/qcadmin/common"
 
 	"github.com/easysoft/qcadmin/internal/app/debug"
 	qcexec "github.com/easysoft/qcadmin/internal/pkg/util/exec"
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -27,6 +30,10 @@ func NewCmdAppGet(f factory.Factory) *cobra.Command {
 		Args:    cobra.ExactArgs(1),
 		Example: `q get app https://example.corp.cc/instance-view-39.html`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := flags.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
 			url := args[0]
 			apidebug := log.GetLevel() == logrus.DebugLevel
 			log.Infof("start fetch app: %s", url)
@@ -34,6 +41,13 @@ func NewCmdAppGet(f factory.Factory) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if format != output.Table {
+				info, err := debug.GetAppInfo(context.Background(), appdata)
+				if err != nil {
+					return err
+				}
+				return format.Write(os.Stdout, info)
+			}
 			extArgs := []string{"exp", "kubectl", "get", "-o", "wide", "pods,deploy,pvc,svc,ing", "-l", "release=" + appdata.K8Name, "--kubeconfig", common.GetKubeConfig()}
 			return qcexec.CommandRun(os.Args[0], extArgs...)
 		},
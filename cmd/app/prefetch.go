@@ -0,0 +1,39 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/pkg/prefetch"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var prefetchExample = templates.Examples(`
+	# pre-pull images of latest zentao on all nodes
+	q app prefetch zentao
+
+	# pre-pull images of a chart version on some nodes
+	q app prefetch zentao@8.5.1 --node 192.168.0.2
+`)
+
+func NewCmdAppPrefetch(f factory.Factory) *cobra.Command {
+	p := prefetch.New(f)
+	cmd := &cobra.Command{
+		Use:     "prefetch <chart>[@version]",
+		Short:   "pre-pull app images on cluster nodes",
+		Example: prefetchExample,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p.ParseChart(args[0])
+			return p.Run()
+		},
+	}
+	cmd.Flags().StringVar(&p.Repo, "repo", p.Repo, "helm repo name")
+	cmd.Flags().StringSliceVar(&p.Nodes, "node", nil, "node ips, default all nodes")
+	return cmd
+}
@@ -42,6 +42,8 @@ func initRootDirectory() error {
 		}
 	}
 
+	// 配置目录包含凭据, 仅当前用户可访问
+	os.Chmod(home+"/"+common.DefaultCfgDir, common.FileMode0700)
 	// TODO 自定义目录可能有问题
 	os.Chmod(common.GetDefaultQuickonBackupDir(""), common.FileMode0777)
 
@@ -7,23 +7,21 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
-
-	"github.com/easysoft/qcadmin/common"
-	qcexec "github.com/easysoft/qcadmin/internal/pkg/util/exec"
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
 	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/easysoft/qcadmin/pkg/diagnose"
 	"github.com/spf13/cobra"
 )
 
 type bugReportCmd struct {
-	log log.Logger
+	log      log.Logger
+	diagnose *diagnose.Option
 }
 
 func newCmdBugReport(f factory.Factory) *cobra.Command {
 	br := bugReportCmd{
-		log: f.GetLog(),
+		log:      f.GetLog(),
+		diagnose: diagnose.New(f),
 	}
 	cmd := &cobra.Command{
 		Use:     "bugreport",
@@ -38,13 +36,12 @@ func newCmdBugReport(f factory.Factory) *cobra.Command {
 }
 
 func (br bugReportCmd) BugReport() error {
-	debugShell := fmt.Sprintf("%s/hack/manifests/scripts/diagnose.sh", common.GetDefaultDataDir())
-	br.log.Debugf("gen debug message script: %v", debugShell)
-	// 移除qcadmin初始化文件
-	if err := qcexec.CommandRun("/bin/bash", debugShell, os.Args[0]); err != nil {
+	dest, err := br.diagnose.Run()
+	if err != nil {
 		return err
 	}
-	bugMsg := "found bug: submit the error message to Github or Gitee\n\t Github: https://github.com/easysoft/quickon_cli/issues/new?assignees=&labels=&template=bug-report.md\n\t Gitee: https://gitee.com/wwccss/qucheng_cli/issues\n"
+	br.log.Donef("diagnose file: %s", dest)
+	bugMsg := "found bug: submit the error message and diagnose file to Github or Gitee\n\t Github: https://github.com/easysoft/quickon_cli/issues/new?assignees=&labels=&template=bug-report.md\n\t Gitee: https://gitee.com/wwccss/qucheng_cli/issues\n"
 	br.log.Info(bugMsg)
 	return nil
 }
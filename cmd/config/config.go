@@ -0,0 +1,203 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/cmd/flags"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/ergoapi/util/file"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	viewExample = templates.Examples(`
+		# view config, secrets are redacted
+		q config view
+
+		# view config in json with secrets
+		q config view -o json --show-secrets`)
+
+	editExample = templates.Examples(`
+		# edit config with $EDITOR, default vi
+		q config edit
+
+		# edit config with nano
+		EDITOR=nano q config edit`)
+)
+
+const editHeader = `# Please edit the q config below. Lines beginning with a '#' will be ignored.
+# Credentials are stored encrypted in a separate file, leave them empty to keep the saved values.
+# An empty file will abort the edit.
+`
+
+// NewCmdConfig returns a cobra command for `config` subcommands
+func NewCmdConfig(f factory.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "view, validate and edit q config",
+	}
+	cmd.AddCommand(viewConfig(f))
+	cmd.AddCommand(validateConfig(f))
+	cmd.AddCommand(editConfig(f))
+	return cmd
+}
+
+// configView 配置无表格形式, table 输出 yaml
+type configView struct {
+	obj interface{}
+}
+
+func (v configView) WriteTable(out io.Writer) error {
+	return output.EncodeYAML(out, v.obj)
+}
+
+func (v configView) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, v.obj)
+}
+
+func (v configView) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, v.obj)
+}
+
+func viewConfig(f factory.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "view",
+		Short:   "show q config, secrets are redacted unless --show-secrets",
+		Example: viewExample,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := flags.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				f.GetLog().Warnf("load config failed, reason: %v", err)
+			}
+			obj, err := cfg.Redacted()
+			if err != nil {
+				return err
+			}
+			return format.Write(os.Stdout, configView{obj: obj})
+		},
+	}
+	return cmd
+}
+
+func validateConfig(f factory.Factory) *cobra.Command {
+	log := f.GetLog()
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "validate q config schema and values",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := common.GetDefaultConfig()
+			if !file.CheckFileExists(path) {
+				return errors.Errorf("config %s not found", path)
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			cfg, migrated, err := config.Decode(b)
+			if err != nil {
+				return errors.Wrapf(err, "config %s is invalid", path)
+			}
+			if err := cfg.Validate(); err != nil {
+				return err
+			}
+			if migrated {
+				log.Warnf("config %s will be migrated to %s on next load", path, config.APIVersion)
+			}
+			log.Donef("config %s is valid", path)
+			return nil
+		},
+	}
+}
+
+func editConfig(f factory.Factory) *cobra.Command {
+	log := f.GetLog()
+	return &cobra.Command{
+		Use:     "edit",
+		Short:   "edit q config with $EDITOR, validated before saved",
+		Example: editExample,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := common.GetDefaultConfig()
+			if !file.CheckFileExists(path) {
+				return errors.Errorf("config %s not found", path)
+			}
+			origin, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			tmp, err := os.CreateTemp("", "q-config-*.yaml")
+			if err != nil {
+				return err
+			}
+			if _, err := tmp.WriteString(editHeader + string(origin)); err != nil {
+				tmp.Close()
+				return err
+			}
+			tmp.Close()
+			editor := os.Getenv("EDITOR")
+			if len(editor) == 0 {
+				editor = "vi"
+			}
+			edit := exec.Command("sh", "-c", editor+" "+tmp.Name())
+			edit.Stdin, edit.Stdout, edit.Stderr = os.Stdin, os.Stdout, os.Stderr
+			if err := edit.Run(); err != nil {
+				os.Remove(tmp.Name())
+				return errors.Wrapf(err, "run editor %s failed", editor)
+			}
+			edited, err := os.ReadFile(tmp.Name())
+			if err != nil {
+				return err
+			}
+			edited = stripComments(edited)
+			if len(bytes.TrimSpace(edited)) == 0 {
+				os.Remove(tmp.Name())
+				log.Warn("edit cancelled, empty file")
+				return nil
+			}
+			if bytes.Equal(edited, stripComments(origin)) {
+				os.Remove(tmp.Name())
+				log.Info("edit cancelled, no changes made")
+				return nil
+			}
+			if _, err := config.ApplyConfig(edited); err != nil {
+				return errors.Wrapf(err, "your changes have been saved to %s", tmp.Name())
+			}
+			os.Remove(tmp.Name())
+			log.Donef("config %s saved", path)
+			return nil
+		},
+	}
+}
+
+// stripComments 去除整行注释
+func stripComments(b []byte) []byte {
+	var lines []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
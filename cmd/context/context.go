@@ -0,0 +1,119 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package context
+
+import (
+	"os"
+
+	"github.com/easysoft/qcadmin/cmd/flags"
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/pkg/contexts"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var addExample = templates.Examples(`
+	# add context of another quickon cluster
+	q context add prod --kubeconfig ./prod.yaml --domain prod.example.com --api-token xxxx
+
+	# add context with exported q config
+	q context add staging --kubeconfig ./staging.yaml --import-config ./staging-cluster.yaml
+
+	# run command against a context without switching
+	q status --context prod`)
+
+// NewCmdContext returns a cobra command for `context` subcommands
+func NewCmdContext(f factory.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "context",
+		Aliases: []string{"ctx"},
+		Short:   "manage named contexts of multiple quickon clusters",
+	}
+	cmd.AddCommand(listContext(f))
+	cmd.AddCommand(useContext(f))
+	cmd.AddCommand(addContext(f))
+	cmd.AddCommand(removeContext(f))
+	return cmd
+}
+
+func listContext(f factory.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "list contexts, current context is marked with *",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := flags.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			list, err := contexts.List()
+			if err != nil {
+				return err
+			}
+			return out.Write(os.Stdout, list)
+		},
+	}
+	return cmd
+}
+
+func useContext(f factory.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:   "use [name]",
+		Short: "switch current context",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := contexts.Use(args[0]); err != nil {
+				return err
+			}
+			f.GetLog().Donef("switched to context %s", args[0])
+			return nil
+		},
+	}
+}
+
+func addContext(f factory.Factory) *cobra.Command {
+	o := &contexts.AddOption{}
+	cmd := &cobra.Command{
+		Use:     "add [name]",
+		Short:   "add context with kubeconfig, config and api token",
+		Example: addExample,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			o.Name = args[0]
+			return o.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Run(); err != nil {
+				return err
+			}
+			f.GetLog().Donef("context %s added, switch to it with: q context use %s", o.Name, o.Name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&o.KubeConfig, "kubeconfig", "", "kubeconfig of the cluster, copied into the context")
+	cmd.Flags().StringVar(&o.Config, "import-config", "", "q config file (cluster.yaml) to import")
+	cmd.Flags().StringVar(&o.Domain, "domain", "", "quickon console domain")
+	cmd.Flags().StringVar(&o.APIToken, "api-token", "", "quickon api token, stored encrypted")
+	return cmd
+}
+
+func removeContext(f factory.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:     "remove [name]",
+		Aliases: []string{"rm", "delete"},
+		Short:   "remove context and its config, credentials and kubeconfig",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := contexts.Remove(args[0]); err != nil {
+				return err
+			}
+			f.GetLog().Donef("context %s removed", args[0])
+			return nil
+		},
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/initsystem"
+	"github.com/easysoft/qcadmin/pkg/daemon"
+	"github.com/kardianos/service"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var daemonExample = templates.Examples(`
+	# run in foreground, metrics are served on :60080/metrics
+	q daemon
+
+	# install and start as system service
+	q daemon install --interval 30s
+
+	# stop and remove system service
+	q daemon uninstall`)
+
+func newCmdDaemon(f factory.Factory) *cobra.Command {
+	o := daemon.New(f)
+	cmd := &cobra.Command{
+		Use:     "daemon",
+		Short:   "Run status collector and expose prometheus metrics",
+		Example: daemonExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := newDaemonService(o)
+			if err != nil {
+				return err
+			}
+			return s.Run()
+		},
+	}
+	cmd.PersistentFlags().StringVar(&o.Listen, "listen", o.Listen, "metrics listen address")
+	cmd.PersistentFlags().DurationVar(&o.Interval, "interval", o.Interval, "collect interval")
+	cmd.PersistentFlags().StringVarP(&o.KubeConfig, "kubeconfig", "c", "", "Kubernetes configuration file, default kubeconfig of current context")
+	cmd.AddCommand(&cobra.Command{
+		Use:   "install",
+		Short: "Install daemon as system service",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := newDaemonService(o)
+			if err != nil {
+				return err
+			}
+			if err := s.Install(); err != nil {
+				return errors.Wrap(err, "install service failed")
+			}
+			if err := s.Start(); err != nil {
+				return errors.Wrap(err, "start service failed")
+			}
+			f.GetLog().Donef("service %s started, metrics are served on %s/metrics", daemon.ServiceName, o.Listen)
+			return nil
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "uninstall",
+		Short: "Stop and remove daemon system service",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := newDaemonService(o)
+			if err != nil {
+				return err
+			}
+			if status, _ := s.Status(); status == service.StatusRunning {
+				if err := s.Stop(); err != nil {
+					return errors.Wrap(err, "stop service failed")
+				}
+			}
+			if err := s.Uninstall(); err != nil {
+				return errors.Wrap(err, "uninstall service failed")
+			}
+			f.GetLog().Donef("service %s removed", daemon.ServiceName)
+			return nil
+		},
+	})
+	return cmd
+}
+
+func newDaemonService(o *daemon.Option) (service.Service, error) {
+	exec, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if len(o.KubeConfig) == 0 {
+		o.KubeConfig = common.GetKubeConfig()
+	}
+	return initsystem.NewService(&initsystem.Config{
+		Name: daemon.ServiceName,
+		Desc: "qcadmin status metrics exporter",
+		Exec: exec,
+		Args: []string{"daemon", "--listen", o.Listen, "--interval", o.Interval.String(), "--kubeconfig", o.KubeConfig, "--context", common.CurrentContext()},
+	}, &initsystem.DaemonService{Run: o.Run})
+}
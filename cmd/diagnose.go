@@ -0,0 +1,43 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/pkg/diagnose"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var diagnoseExample = templates.Examples(`
+	# collect diagnose info of host, quickon, kubernetes, helm and all nodes
+	q diagnose
+
+	# collect last hour logs without ssh to nodes
+	q diagnose --since 1h --skip-nodes -f /root/diagnose.tar.gz`)
+
+func newCmdDiagnose(f factory.Factory) *cobra.Command {
+	o := diagnose.New(f)
+	cmd := &cobra.Command{
+		Use:     "diagnose",
+		Short:   "Collect diagnose info into a tar.gz",
+		Example: diagnoseExample,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dest, err := o.Run()
+			if err != nil {
+				return err
+			}
+			f.GetLog().Donef("diagnose file: %s", dest)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dest, "file", "f", "", "diagnose file path, default /tmp/qdiagnose-<timestamp>.tar.gz")
+	cmd.Flags().DurationVar(&o.Since, "since", o.Since, "collect container logs newer than a relative duration")
+	cmd.Flags().BoolVar(&o.SkipNodes, "skip-nodes", false, "skip collecting node info over ssh")
+	return cmd
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/easysoft/qcadmin/cmd/flags"
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/pkg/events"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var eventsExample = templates.Examples(`
+	# warning events of all namespaces in the last hour
+	q events --type Warning --since 1h
+
+	# watch events of app release
+	q events --app zentao-abcd --watch
+
+	# events of namespace in json
+	q events --ns quickon-system -o json`)
+
+func newCmdEvents(f factory.Factory) *cobra.Command {
+	o := &events.Option{}
+	cmd := &cobra.Command{
+		Use:     "events",
+		Aliases: []string{"event", "ev"},
+		Short:   "List cluster events, mapped to quickon apps by release label",
+		Example: eventsExample,
+		Args:    cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			format, err := flags.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			o.Output = format
+			return o.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return o.Run(ctx, os.Stdout)
+		},
+	}
+	cmd.Flags().StringVar(&o.App, "app", "", "app release name, namespace defaults to quickon-app")
+	cmd.Flags().StringVar(&o.Namespace, "ns", "", "namespace, default all namespaces")
+	cmd.Flags().DurationVar(&o.Since, "since", time.Hour, "only show events newer than a relative duration, 0 for all")
+	cmd.Flags().BoolVarP(&o.Watch, "watch", "w", false, "watch for new events after listing")
+	cmd.Flags().StringVar(&o.Type, "type", "", "event type, Normal or Warning")
+	return cmd
+}
@@ -7,16 +7,24 @@
 package flags
 
 import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
 )
 
 // GlobalFlags is the flags that contains the global flags
 type GlobalFlags struct {
-	Debug      bool
-	Silent     bool
-	ConfigPath string
-	Vars       []string
-	Flags      *flag.FlagSet
+	Debug       bool
+	Silent      bool
+	ConfigPath  string
+	ShowSecrets bool
+	Context     string
+	Output      string
+	Vars        []string
+	Flags       *flag.FlagSet
 }
 
 // SetGlobalFlags applies the global flags
@@ -28,5 +36,22 @@ func SetGlobalFlags(flags *flag.FlagSet) *GlobalFlags {
 	flags.BoolVar(&globalFlags.Debug, "debug", false, "Prints the stack trace if an error occurs")
 	flags.BoolVar(&globalFlags.Silent, "silent", false, "Run in silent mode and prevents any qcadmin log output except panics & fatals")
 	flags.StringVar(&globalFlags.ConfigPath, "config", "", "The qcadmin config file to use")
+	flags.BoolVar(&globalFlags.ShowSecrets, "show-secrets", false, "Show passwords and tokens in config output and diagnose files instead of masking them")
+	flags.StringVar(&globalFlags.Context, "context", "", "The qcadmin context to use, default current context of q context use")
+	flags.StringVarP(&globalFlags.Output, "output", "o", "", "Prints the output in the specified format. Allowed values: table, json, yaml (default table)")
 	return globalFlags
 }
+
+// OutputFormat --output 对应的输出格式, 未设置时为 table.
+// 子命令同名的本地 --output 优先
+func OutputFormat(cmd *cobra.Command) (output.Format, error) {
+	format, _ := cmd.Flags().GetString("output")
+	if len(format) == 0 {
+		return output.Table, nil
+	}
+	out, err := output.ParseFormat(strings.ToLower(format))
+	if err != nil {
+		return out, errors.Errorf("unsupported output %s, only support %s", format, strings.Join(output.Formats(), ","))
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package hub
+
+import (
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/cmd/flags"
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/pkg/hub"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	pushLong = templates.LongDesc(`
+		Push charts and images to hub.
+
+		Images are imported and pushed through the containerd of k3s on this host,
+		so k3s must be installed first. In offline mode the order is:
+		q offline load, q hub init, q init --offline, then q hub push --staged
+		so that other nodes can pull images from hub. Charts can be pushed any time after q hub init.`)
+
+	pushExample = templates.Examples(`
+		# push chart and images
+		q hub push mychart-1.0.0.tgz images.tar nginx:1.25
+
+		# push all images staged by q offline load, after q init --offline
+		q hub push --staged
+`)
+)
+
+// NewCmdHub returns a cobra command for `hub` subcommands
+func NewCmdHub(f factory.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hub",
+		Short: "manage local image registry and chart repo for offline mode",
+	}
+	cmd.AddCommand(initHub(f))
+	cmd.AddCommand(statusHub(f))
+	cmd.AddCommand(pushHub(f))
+	cmd.AddCommand(listHub(f))
+	return cmd
+}
+
+func initHub(f factory.Factory) *cobra.Command {
+	h := hub.New(f)
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "install and start hub services",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.Init()
+		},
+	}
+	cmd.Flags().StringVar(&h.IP, "ip", "", "hub address used by cluster nodes, default local ip")
+	return cmd
+}
+
+func statusHub(f factory.Factory) *cobra.Command {
+	h := hub.New(f)
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "show hub services status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := flags.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			status := h.Status()
+			if err := format.Write(os.Stdout, status); err != nil {
+				return err
+			}
+			unhealthy := 0
+			for _, s := range status {
+				if !s.Healthy {
+					unhealthy++
+				}
+			}
+			if unhealthy > 0 {
+				return errors.Errorf("%d hub service unhealthy", unhealthy)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func pushHub(f factory.Factory) *cobra.Command {
+	h := hub.New(f)
+	var staged bool
+	cmd := &cobra.Command{
+		Use:     "push [chart.tgz|images.tar|image]...",
+		Short:   "push charts and images to hub",
+		Long:    pushLong,
+		Example: pushExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if staged {
+				if err := h.PushStaged(); err != nil {
+					return err
+				}
+			}
+			if len(args) == 0 && !staged {
+				return errors.New("nothing to push")
+			}
+			return h.Push(args)
+		},
+	}
+	cmd.Flags().BoolVar(&staged, "staged", false, "push images staged by q offline load")
+	return cmd
+}
+
+func listHub(f factory.Factory) *cobra.Command {
+	h := hub.New(f)
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "list images and charts in hub",
+		Aliases: []string{"ls"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := flags.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			catalog, err := h.List()
+			if err != nil {
+				return err
+			}
+			return format.Write(os.Stdout, catalog)
+		},
+	}
+	return cmd
+}
@@ -35,13 +35,15 @@ var (
 		Use:   "init",
 		Short: "Initialize a Kubernetes & Quickon cluster",
 	}
-	skip    bool
-	appName string
+	skip     bool
+	appName  string
+	prefetch bool
 )
 
 func init() {
 	initCmd.PersistentFlags().BoolVar(&skip, "skip-precheck", false, "skip precheck")
 	initCmd.PersistentFlags().StringVar(&appName, "app", "zentao", "app name")
+	initCmd.PersistentFlags().BoolVar(&prefetch, "prefetch", false, "pre-pull app images on all nodes before install")
 }
 
 func newCmdInit(f factory.Factory) *cobra.Command {
@@ -66,6 +68,9 @@ func newCmdInit(f factory.Factory) *cobra.Command {
 			log.Donef("quickon is already initialized, just run %s get cluster status", color.SGreen("%s status", globalToolPath))
 			os.Exit(0)
 		}
+		// 共用代理参数
+		nCluster.Proxy = quickonClient.Proxy
+		quickonClient.SetProxyEnv()
 		if name == "incluster" {
 			// TODO Check k8s ready
 			if _, err := k8s.NewSimpleClient(); err != nil {
@@ -116,6 +121,12 @@ func newCmdInit(f factory.Factory) *cobra.Command {
 			log.Errorf("init quickon failed, reason: %v", err)
 			return
 		}
+		if prefetch {
+			// 预拉取失败不影响安装
+			if err := qcexec.CommandRun(globalToolPath, "quickon", "app", "prefetch", appName, fmt.Sprintf("--debug=%v", globalFlags.Debug)); err != nil {
+				log.Warnf("prefetch app %s images failed, reason: %v", appName, err)
+			}
+		}
 		if err := qcexec.CommandRun(globalToolPath, "quickon", "app", "install", "--name", appName, "--api-useip", fmt.Sprintf("--debug=%v", globalFlags.Debug)); err != nil {
 			log.Errorf("init quickon failed, reason: %v", err)
 			return
@@ -0,0 +1,25 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/easysoft/qcadmin/cmd/manage"
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/spf13/cobra"
+)
+
+func newCmdManage(f factory.Factory) *cobra.Command {
+	manageCmd := &cobra.Command{
+		Use:   "manage",
+		Short: "Manage Quickon settings",
+	}
+	manageCmd.AddCommand(manage.NewCmdTLS(f))
+	manageCmd.AddCommand(manage.NewCmdDomain(f))
+	manageCmd.AddCommand(manage.NewRenewTLS(f))
+	manageCmd.AddCommand(manage.NewResetPassword(f))
+	return manageCmd
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package manage
+
+import (
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/pkg/quickon"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var domainSetExample = templates.Examples(`
+	# switch to company domain, tls disabled unless cert provided
+	q manage domain set example.com
+
+	# switch domain with wildcard cert of *.example.com and rewrite app ingress
+	q manage domain set example.com --tls-cert cert.pem --tls-key key.pem --rewrite-apps
+`)
+
+func NewCmdDomain(f factory.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "domain",
+		Short: "manage quickon domain",
+	}
+	cmd.AddCommand(setDomainCmd(f))
+	return cmd
+}
+
+func setDomainCmd(f factory.Factory) *cobra.Command {
+	quickonClient := quickon.New(f)
+	var rewriteApps bool
+	cmd := &cobra.Command{
+		Use:     "set <domain>",
+		Short:   "change quickon domain",
+		Example: domainSetExample,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			quickonClient.Domain = args[0]
+			if err := quickonClient.GetKubeClient(); err != nil {
+				return err
+			}
+			return quickonClient.SetDomain(rewriteApps)
+		},
+	}
+	cmd.Flags().StringVar(&quickonClient.TLSCert, "tls-cert", "", "tls cert file, should match *.domain")
+	cmd.Flags().StringVar(&quickonClient.TLSKey, "tls-key", "", "tls key file")
+	cmd.Flags().BoolVar(&rewriteApps, "rewrite-apps", false, "rewrite host of installed app ingress")
+	return cmd
+}
@@ -9,12 +9,17 @@ package manage
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 
+	"github.com/easysoft/qcadmin/cmd/flags"
 	"github.com/easysoft/qcadmin/internal/app/config"
 	"github.com/easysoft/qcadmin/internal/pkg/k8s"
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
 	quchengv1beta1 "github.com/easysoft/quickon-api/qucheng/v1beta1"
 	"github.com/ergoapi/util/exmap"
+	"github.com/gosuri/uitable"
 	"github.com/manifoldco/promptui"
 	"github.com/pkg/browser"
 	"github.com/spf13/cobra"
@@ -26,6 +31,50 @@ type action struct {
 	Name string
 }
 
+// gdbInfo 全局数据库服务信息, 不包含账号密码
+type gdbInfo struct {
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Name      string `json:"name" yaml:"name"`
+	Type      string `json:"type" yaml:"type"`
+	Address   string `json:"address" yaml:"address"`
+	Ready     bool   `json:"ready" yaml:"ready"`
+	ChildDB   int64  `json:"childDB" yaml:"childDB"`
+}
+
+type gdbList []gdbInfo
+
+func (l gdbList) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.AddRow("NAMESPACE", "NAME", "TYPE", "ADDRESS", "READY", "CHILD DB")
+	for _, g := range l {
+		table.AddRow(g.Namespace, g.Name, g.Type, g.Address, g.Ready, g.ChildDB)
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (l gdbList) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, l)
+}
+
+func (l gdbList) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, l)
+}
+
+func newGdbList(dbsvcs []quchengv1beta1.DbService) gdbList {
+	list := gdbList{}
+	for _, dbsvc := range dbsvcs {
+		list = append(list, gdbInfo{
+			Namespace: dbsvc.Namespace,
+			Name:      dbsvc.Name,
+			Type:      string(dbsvc.Spec.Type),
+			Address:   dbsvc.Status.Address,
+			Ready:     dbsvc.Status.Ready != nil && *dbsvc.Status.Ready,
+			ChildDB:   dbsvc.Status.ChildDB,
+		})
+	}
+	return list
+}
+
 func NewCmdGdbList(f factory.Factory) *cobra.Command {
 	log := f.GetLog()
 	var name string
@@ -34,6 +83,10 @@ func NewCmdGdbList(f factory.Factory) *cobra.Command {
 		Short:   "list gdb",
 		Example: `g gdb list`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := flags.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
 			cfg, _ := config.LoadConfig()
 			qclient, err := k8s.NewSimpleQClient()
 			if err != nil {
@@ -43,16 +96,20 @@ func NewCmdGdbList(f factory.Factory) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			if len(dbsvcs.Items) == 0 {
-				log.Warn("no found global database service")
-				return nil
-			}
 			var gdbServices []quchengv1beta1.DbService
 			for _, dbsvc := range dbsvcs.Items {
 				if vaildGlobalDatabase(dbsvc.Labels) {
 					gdbServices = append(gdbServices, dbsvc)
 				}
 			}
+			// 结构化输出时仅列出, 不进入交互选择
+			if format != output.Table {
+				return format.Write(os.Stdout, newGdbList(gdbServices))
+			}
+			if len(gdbServices) == 0 {
+				log.Warn("no found global database service")
+				return nil
+			}
 			selectGDB := promptui.Select{
 				Label: "select global db service",
 				Items: gdbServices,
@@ -7,20 +7,24 @@
 package manage
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"os"
 	"strings"
 
+	"github.com/easysoft/qcadmin/cmd/flags"
 	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
 	"github.com/easysoft/qcadmin/internal/pkg/k8s"
 	pluginapi "github.com/easysoft/qcadmin/internal/pkg/plugin"
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
 	"github.com/easysoft/qcadmin/internal/pkg/util/output"
 	"github.com/gosuri/uitable"
 	"github.com/spf13/cobra"
 )
 
-var show string
-
 func NewCmdPlugin(f factory.Factory) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "plugins",
@@ -31,6 +35,10 @@ func NewCmdPlugin(f factory.Factory) *cobra.Command {
 	cmd.AddCommand(installPluginCmd(f))
 	cmd.AddCommand(unInstallPluginCmd(f))
 	cmd.AddCommand(upgradePluginCmd(f))
+	cmd.AddCommand(updatePluginIndexCmd(f))
+	cmd.AddCommand(diffPluginCmd(f))
+	cmd.AddCommand(statusPluginCmd(f))
+	cmd.AddCommand(rollbackPluginCmd(f))
 	return cmd
 }
 
@@ -52,49 +60,106 @@ func NewCmdPlugin(f factory.Factory) *cobra.Command {
 // 	return sync
 // }
 
+// pluginList 插件索引, 表格输出时附带已安装版本
+type pluginList struct {
+	plugins []pluginapi.Meta
+	client  *k8s.Client
+}
+
+func (l pluginList) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.MaxColWidth = 80
+	table.Wrap = true
+	table.AddRow("TYPE", "NAME", "AVAILABLE", "INSTALLED", "DEFAULT", "DEPENDENCIES")
+	for _, d := range l.plugins {
+		installedName, installedVersion, _ := pluginapi.InstalledVersion(context.TODO(), l.client, d.Type)
+		for _, v := range d.Item {
+			installed := "-"
+			if installedName == v.Name {
+				installed = installedVersion
+			}
+			isDefault := ""
+			if v.Name == d.Default {
+				isDefault = "*"
+			}
+			deps := "-"
+			if len(v.Dependencies) > 0 {
+				deps = strings.Join(v.Dependencies, ",")
+			}
+			table.AddRow(d.Type, v.Name, v.Version, installed, isDefault, deps)
+		}
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (l pluginList) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, l.plugins)
+}
+
+func (l pluginList) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, l.plugins)
+}
+
 func listPluginCmd(f factory.Factory) *cobra.Command {
 	listcmd := &cobra.Command{
 		Use:     "list",
 		Short:   "list plugin",
 		Aliases: []string{"ls"},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := flags.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
 			ps, err := pluginapi.GetAll()
 			if err != nil {
 				return err
 			}
-			switch strings.ToLower(show) {
-			case "json":
-				return output.EncodeJSON(os.Stdout, ps)
-			case "yaml":
-				return output.EncodeYAML(os.Stdout, ps)
-			default:
-				table := uitable.New()
-				table.MaxColWidth = 80
-				table.Wrap = true
-				for _, d := range ps {
-					table.AddRow("type: ", d.Type)
-					table.AddRow("default: ", d.Default)
-					if len(d.Item) > 1 {
-						str := []string{}
-						for _, v := range d.Item {
-							if v.Name != d.Default {
-								str = append(str, v.Name)
-							}
-						}
-						table.AddRow("optional: ", strings.Join(str, ", "))
-					}
-					table.AddRow("------------")
-				}
-				return output.EncodeTable(os.Stdout, table)
+			list := pluginList{plugins: ps}
+			if format == output.Table {
+				list.client, _ = k8s.NewClient("", "")
 			}
+			return format.Write(os.Stdout, list)
 		},
 	}
-	listcmd.Flags().StringVarP(&show, "output", "o", "", "prints the output in the specified format. Allowed values: table, json, yaml (default table)")
 	return listcmd
 }
 
+func updatePluginIndexCmd(f factory.Factory) *cobra.Command {
+	var url string
+	var save bool
+	log := f.GetLog()
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "update plugin index from remote",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.StartWait("fetch plugin index from remote...")
+			idx, err := pluginapi.UpdateIndex(url)
+			log.StopWait()
+			if err != nil {
+				return err
+			}
+			if save && len(url) > 0 {
+				cfg, err := config.LoadConfig()
+				if err != nil {
+					return err
+				}
+				cfg.Plugin.Index = url
+				if err := cfg.SaveConfig(); err != nil {
+					log.Warnf("save plugin index url failed, reason: %v", err)
+				}
+			}
+			log.Donef("update plugin index success, source: %s, count: %d", idx.Source, len(idx.Plugins))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&url, "url", "", fmt.Sprintf("plugin index url (default %s)", common.DefaultPluginIndexURL))
+	cmd.Flags().BoolVar(&save, "save", true, "save the index url as default")
+	return cmd
+}
+
 func installPluginCmd(f factory.Factory) *cobra.Command {
 	var version, kubecfg string
+	var sets, files []string
 	cmd := &cobra.Command{
 		Use:     "enable",
 		Short:   "install plugin",
@@ -105,20 +170,34 @@ func installPluginCmd(f factory.Factory) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if len(kubecfg) == 0 {
+				kubecfg = common.GetKubeConfig()
+			}
 			c, err := k8s.NewClient("", kubecfg)
 			if err != nil {
 				return err
 			}
+			values, err := helm.ParseValues(files, sets)
+			if err != nil {
+				return err
+			}
 			ps.Client = c
 			ps.InstallVersion = version
+			ps.Values = values
 			return ps.Install()
 		},
 	}
 	cmd.Flags().StringVarP(&version, "version", "v", "", "plugin")
-	cmd.Flags().StringVarP(&kubecfg, "kubeconfig", "k", common.GetKubeConfig(), "kubeconfig file")
+	cmd.Flags().StringVarP(&kubecfg, "kubeconfig", "k", "", "kubeconfig file, default kubeconfig of current context")
+	addPluginValuesFlags(cmd, &sets, &files)
 	return cmd
 }
 
+func addPluginValuesFlags(cmd *cobra.Command, sets, files *[]string) {
+	cmd.Flags().StringArrayVar(sets, "set", []string{}, "set plugin values on the command line (e.g. '--set key1=value1,key2=value2')")
+	cmd.Flags().StringArrayVarP(files, "values", "f", []string{}, "specify plugin values in a YAML file (can specify multiple)")
+}
+
 func unInstallPluginCmd(f factory.Factory) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "disable",
@@ -142,6 +221,8 @@ func unInstallPluginCmd(f factory.Factory) *cobra.Command {
 }
 
 func upgradePluginCmd(f factory.Factory) *cobra.Command {
+	var version string
+	var sets, files []string
 	cmd := &cobra.Command{
 		Use:     "upgrade",
 		Short:   "Upgrade plugin",
@@ -156,9 +237,154 @@ func upgradePluginCmd(f factory.Factory) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			values, err := helm.ParseValues(files, sets)
+			if err != nil {
+				return err
+			}
 			ps.Client = c
+			ps.InstallVersion = version
+			ps.Values = values
 			return ps.Upgrade()
 		},
 	}
+	cmd.Flags().StringVarP(&version, "version", "v", "", "plugin version")
+	addPluginValuesFlags(cmd, &sets, &files)
+	return cmd
+}
+
+func rollbackPluginCmd(f factory.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "rollback plugin to the previous version",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ps, err := pluginapi.GetMeta(args...)
+			if err != nil {
+				return err
+			}
+			c, err := k8s.NewClient("", "")
+			if err != nil {
+				return err
+			}
+			ps.Client = c
+			return ps.Rollback()
+		},
+	}
+	return cmd
+}
+
+func diffPluginCmd(f factory.Factory) *cobra.Command {
+	var version string
+	var sets, files []string
+	log := f.GetLog()
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "show manifest changes of plugin upgrade",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ps, err := pluginapi.GetMeta(args...)
+			if err != nil {
+				return err
+			}
+			c, err := k8s.NewClient("", "")
+			if err != nil {
+				return err
+			}
+			values, err := helm.ParseValues(files, sets)
+			if err != nil {
+				return err
+			}
+			ps.Client = c
+			ps.InstallVersion = version
+			ps.Values = values
+			diffs, err := ps.Diff()
+			if err != nil {
+				return err
+			}
+			if len(diffs) == 0 {
+				log.Donef("plugin %s has no changes", ps.Type)
+				return nil
+			}
+			for _, d := range diffs {
+				fmt.Fprintf(os.Stdout, "%s %s\n%s\n\n", d.Resource, d.Action, d.Diff)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&version, "version", "v", "", "plugin version")
+	addPluginValuesFlags(cmd, &sets, &files)
 	return cmd
 }
+
+type pluginStates []pluginapi.State
+
+func (l pluginStates) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.MaxColWidth = 60
+	table.Wrap = true
+	table.AddRow("TYPE", "NAME", "VERSION", "HEALTH", "WORKLOADS", "INSTALLED", "UPDATED", "VALUES", "LAST OPERATION", "ERROR")
+	for _, s := range l {
+		var workloads []string
+		for _, w := range s.Workloads {
+			workloads = append(workloads, fmt.Sprintf("%s/%s %d/%d", w.Kind, w.Name, w.Ready, w.Desired))
+		}
+		lastOp := "-"
+		if len(s.LastOperation) > 0 {
+			lastOp = fmt.Sprintf("%s(%s)", s.LastOperation, s.LastResult)
+		}
+		table.AddRow(s.Type, orDash(s.Name), orDash(s.Version), s.Health, orDash(strings.Join(workloads, "\n")), orDash(s.InstallTime), orDash(s.UpdateTime), orDash(s.ValuesHash), lastOp, orDash(s.LastError))
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (l pluginStates) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, l)
+}
+
+func (l pluginStates) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, l)
+}
+
+func statusPluginCmd(f factory.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status [type]",
+		Short: "show plugin status",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := flags.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			c, err := k8s.NewClient("", "")
+			if err != nil {
+				return err
+			}
+			var states pluginStates
+			if len(args) == 1 {
+				ps, err := pluginapi.GetMeta(args...)
+				if err != nil {
+					return err
+				}
+				state, err := pluginapi.GetState(context.TODO(), c, ps.Type)
+				if state == nil {
+					return err
+				}
+				states = append(states, *state)
+			} else {
+				states, err = pluginapi.GetStates(context.TODO(), c)
+				if err != nil {
+					return err
+				}
+			}
+			return format.Write(os.Stdout, states)
+		},
+	}
+	return cmd
+}
+
+func orDash(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return s
+}
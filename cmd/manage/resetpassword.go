@@ -45,7 +45,11 @@ func NewResetPassword(f factory.Factory) *cobra.Command {
 		Short:   "reset quickon admin password",
 		Aliases: []string{"rp", "re-pass"},
 		Run: func(cmd *cobra.Command, args []string) {
-			cfg, _ := config.LoadConfig()
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				log.Errorf("load config failed, reason: %v", err)
+				return
+			}
 			if cfg.APIToken == "" {
 				k8sClient, err := k8s.NewSimpleClient()
 				if err != nil {
@@ -105,7 +109,9 @@ func NewResetPassword(f factory.Factory) *cobra.Command {
 				return
 			}
 			cfg.ConsolePassword = password
-			cfg.SaveConfig()
+			if err := cfg.SaveConfig(); err != nil {
+				log.Warnf("save console password failed, reason: %v", err)
+			}
 			log.Donef("gen admin %s password %s success.", color.SGreen(result.Data.Account), color.SGreen(password))
 		},
 	}
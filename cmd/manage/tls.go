@@ -0,0 +1,246 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package manage
+
+import (
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/cmd/flags"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/httptls"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/easysoft/qcadmin/pkg/quickon"
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	tlsSetExample = templates.Examples(`
+		# use your own wildcard cert of *.example.com
+		q manage tls set --tls-cert cert.pem --tls-key key.pem
+`)
+
+	tlsACMELong = templates.LongDesc(`
+		Issue tls cert of custom domain by cert-manager.
+
+		The http01 solver can not issue wildcard certs, only console.<domain> and s3.<domain>
+		are covered, apps under *.<domain> keep serving without a valid cert.
+		Use the dns01 solver to issue a wildcard cert for all apps.`)
+
+	tlsACMEExample = templates.Examples(`
+		# issue console.example.com and s3.example.com cert from let's encrypt via nginx ingress, apps are not covered
+		q manage tls acme --email admin@example.com
+
+		# issue wildcard cert *.example.com with cloudflare dns
+		q manage tls acme --email admin@example.com --solver dns01 --dns-provider cloudflare --dns-token xxx
+
+		# verify with a local pebble server
+		q manage tls acme --email admin@example.com --server https://pebble.pebble:14000/dir --skip-tls-verify
+`)
+
+	tlsListExample = templates.Examples(`
+		# list certs of all ingress
+		q manage tls list
+
+		# exit nonzero when any cert expires within 14 days, for cron alerting
+		q manage tls list --expiring-within 14d
+`)
+
+	tlsCAExample = templates.Examples(`
+		# export offline ca bundle and show how to trust it
+		q manage tls ca -f quickon-ca.pem
+`)
+)
+
+func NewCmdTLS(f factory.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tls",
+		Short: "manage quickon tls cert",
+	}
+	cmd.AddCommand(setTLSCmd(f))
+	cmd.AddCommand(acmeTLSCmd(f))
+	cmd.AddCommand(listTLSCmd(f))
+	cmd.AddCommand(caTLSCmd(f))
+	return cmd
+}
+
+func setTLSCmd(f factory.Factory) *cobra.Command {
+	quickonClient := quickon.New(f)
+	cmd := &cobra.Command{
+		Use:     "set",
+		Short:   "set tls cert of custom domain",
+		Example: tlsSetExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := quickonClient.GetKubeClient(); err != nil {
+				return err
+			}
+			return quickonClient.SetTLS()
+		},
+	}
+	cmd.Flags().StringVar(&quickonClient.Domain, "domain", "", "quickon domain, default current domain")
+	cmd.Flags().StringVar(&quickonClient.TLSCert, "tls-cert", "", "tls cert file, should match *.domain")
+	cmd.Flags().StringVar(&quickonClient.TLSKey, "tls-key", "", "tls key file")
+	return cmd
+}
+
+func acmeTLSCmd(f factory.Factory) *cobra.Command {
+	quickonClient := quickon.New(f)
+	var opts httptls.ACMEOptions
+	var wait time.Duration
+	cmd := &cobra.Command{
+		Use:     "acme",
+		Short:   "issue tls cert of custom domain by cert-manager",
+		Long:    tlsACMELong,
+		Example: tlsACMEExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := quickonClient.GetKubeClient(); err != nil {
+				return err
+			}
+			return quickonClient.SetACME(opts, wait)
+		},
+	}
+	cmd.Flags().StringVar(&opts.Email, "email", "", "acme account email")
+	cmd.Flags().StringVar(&opts.Server, "server", "", "acme directory url, default let's encrypt")
+	cmd.Flags().BoolVar(&opts.SkipTLSVerify, "skip-tls-verify", false, "skip tls verify of acme server, e.g. pebble")
+	cmd.Flags().StringVar(&opts.Solver, "solver", httptls.SolverHTTP01, "acme challenge solver, http01 (console and s3 only) or dns01 (wildcard, covers apps)")
+	cmd.Flags().StringVar(&opts.IngressClass, "ingress-class", "", "ingress class of http01 solver, default cluster default ingress class")
+	cmd.Flags().StringVar(&opts.DNSProvider, "dns-provider", "cloudflare", "dns01 provider")
+	cmd.Flags().StringVar(&opts.DNSToken, "dns-token", "", "dns01 provider api token")
+	cmd.Flags().DurationVar(&wait, "wait", 5*time.Minute, "wait for certificate ready, 0 means no wait")
+	return cmd
+}
+
+type certList []httptls.IngressCert
+
+func (l certList) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.MaxColWidth = 60
+	table.Wrap = true
+	table.AddRow("NAMESPACE", "SECRET", "DOMAINS", "ISSUER", "EXPIRES", "DAYS", "INGRESSES")
+	for _, c := range l {
+		expires, days := "-", "-"
+		if len(c.Error) > 0 {
+			expires = c.Error
+		} else {
+			expires = c.NotAfter.Format("2006-01-02")
+			days = strconv.Itoa(int(time.Until(c.NotAfter).Hours() / 24))
+		}
+		table.AddRow(c.Namespace, c.Secret, orDash(strings.Join(c.Domains, ",")), orDash(c.Issuer), expires, days, strings.Join(c.Ingresses, ","))
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (l certList) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, l)
+}
+
+func (l certList) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, l)
+}
+
+func listTLSCmd(f factory.Factory) *cobra.Command {
+	var within string
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "list tls certs referenced by ingress",
+		Aliases: []string{"ls"},
+		Example: tlsListExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := flags.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			var expiring time.Duration
+			if len(within) > 0 {
+				d, err := parseDayDuration(within)
+				if err != nil {
+					return err
+				}
+				expiring = d
+			}
+			c, err := k8s.NewClient("", "")
+			if err != nil {
+				return err
+			}
+			certs, err := httptls.ScanIngressCerts(context.TODO(), c)
+			if err != nil {
+				return err
+			}
+			if expiring > 0 {
+				var filtered []httptls.IngressCert
+				for _, cert := range certs {
+					if cert.ExpiringWithin(expiring) {
+						filtered = append(filtered, cert)
+					}
+				}
+				certs = filtered
+			}
+			if err := format.Write(os.Stdout, certList(certs)); err != nil {
+				return err
+			}
+			if expiring > 0 && len(certs) > 0 {
+				return errors.Errorf("%d tls cert expiring within %s", len(certs), within)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&within, "expiring-within", "", "only show certs expiring within the duration (e.g. 14d, 72h), exit nonzero if any")
+	return cmd
+}
+
+// parseDayDuration 在 time.ParseDuration 基础上支持天, 如 14d
+func parseDayDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 {
+			return 0, errors.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, errors.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func caTLSCmd(f factory.Factory) *cobra.Command {
+	var out string
+	log := f.GetLog()
+	cmd := &cobra.Command{
+		Use:     "ca",
+		Short:   "export offline self-signed ca bundle",
+		Example: tlsCAExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ca, err := os.ReadFile(httptls.CAFile())
+			if err != nil {
+				return errors.Wrap(err, "offline ca not found, only available for offline install")
+			}
+			if len(out) == 0 {
+				_, err = os.Stdout.Write(ca)
+				return err
+			}
+			if err := os.WriteFile(out, ca, 0644); err != nil {
+				return err
+			}
+			log.Donef("export ca bundle to %s", out)
+			log.Info(httptls.TrustInstructions(out))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&out, "file", "f", "", "export ca bundle to file, default stdout")
+	return cmd
+}
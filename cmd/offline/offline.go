@@ -0,0 +1,76 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package offline
+
+import (
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/pkg/offline"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	buildExample = templates.Examples(`
+		# build offline bundle of quickon stable-2.6
+		q offline build --version stable-2.6 -f bundle.tar.zst
+
+		# build bundle for arm64 nodes without images
+		q offline build --arch arm64 --skip-images
+
+		# bundle more market apps with their images
+		q offline build --app zentao,gitea
+`)
+
+	loadExample = templates.Examples(`
+		# stage offline bundle on the target node, then init offline
+		q offline load bundle.tar.zst
+		q init --offline
+`)
+)
+
+// NewCmdOffline returns a cobra command for `offline` subcommands
+func NewCmdOffline(f factory.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "offline",
+		Short: "build or load air-gapped bundle",
+	}
+	cmd.AddCommand(build(f))
+	cmd.AddCommand(load(f))
+	return cmd
+}
+
+func build(f factory.Factory) *cobra.Command {
+	o := offline.New(f)
+	cmd := &cobra.Command{
+		Use:     "build",
+		Short:   "build offline bundle",
+		Example: buildExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.Build()
+		},
+	}
+	cmd.Flags().StringVar(&o.Version, "version", o.Version, "quickon version")
+	cmd.Flags().StringVarP(&o.Output, "file", "f", o.Output, "bundle file")
+	cmd.Flags().StringVar(&o.Arch, "arch", o.Arch, "target arch, amd64 or arm64")
+	cmd.Flags().BoolVar(&o.SkipImages, "skip-images", false, "do not save chart images")
+	cmd.Flags().StringSliceVar(&o.Apps, "app", o.Apps, "market app charts to bundle with their images")
+	return cmd
+}
+
+func load(f factory.Factory) *cobra.Command {
+	o := offline.New(f)
+	cmd := &cobra.Command{
+		Use:     "load <bundle>",
+		Short:   "load offline bundle on target node",
+		Example: loadExample,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.Load(args[0])
+		},
+	}
+	return cmd
+}
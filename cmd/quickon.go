@@ -26,6 +26,7 @@ func newCmdApp(f factory.Factory) *cobra.Command {
 	appCmd.AddCommand(app.NewCmdAppList(f))
 	appCmd.AddCommand(app.NewCmdAppInstall(f))
 	appCmd.AddCommand(app.NewCmdAppMarket(f))
+	appCmd.AddCommand(app.NewCmdAppPrefetch(f))
 	return appCmd
 }
 
@@ -37,6 +37,7 @@ func InitCommand(f factory.Factory) *cobra.Command {
 		Use:   "init",
 		Short: "init quickon",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			quickonClient.SetProxyEnv()
 			if err := quickonClient.GetKubeClient(); err != nil {
 				return err
 			}
@@ -11,11 +11,22 @@ import (
 	"os"
 	"strings"
 
+	"github.com/cockroachdb/errors"
+	configcmd "github.com/easysoft/qcadmin/cmd/config"
+	contextcmd "github.com/easysoft/qcadmin/cmd/context"
 	"github.com/easysoft/qcadmin/cmd/flags"
+	"github.com/easysoft/qcadmin/cmd/hub"
+	"github.com/easysoft/qcadmin/cmd/offline"
 	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
 	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/easysoft/qcadmin/internal/pkg/util/proxy"
+	"github.com/easysoft/qcadmin/internal/pkg/util/redact"
+	"github.com/easysoft/qcadmin/pkg/contexts"
 	"github.com/ergoapi/util/excmd"
+	"github.com/ergoapi/util/exnet"
 	mcobra "github.com/muesli/mango-cobra"
 	"github.com/muesli/roff"
 	"github.com/sirupsen/logrus"
@@ -64,13 +75,21 @@ func BuildRoot(f factory.Factory) *cobra.Command {
 	rootCmd.AddCommand(newCmdInit(f))
 	rootCmd.AddCommand(newCmdUninstall(f))
 	rootCmd.AddCommand(newCmdStatus(f))
+	rootCmd.AddCommand(newCmdEvents(f))
 	rootCmd.AddCommand(newCmdUpgrade(f))
 	rootCmd.AddCommand(newCmdCluster(f))
 	rootCmd.AddCommand(newCmdQuickon(f))
+	rootCmd.AddCommand(newCmdManage(f))
+	rootCmd.AddCommand(offline.NewCmdOffline(f))
+	rootCmd.AddCommand(hub.NewCmdHub(f))
+	rootCmd.AddCommand(configcmd.NewCmdConfig(f))
+	rootCmd.AddCommand(contextcmd.NewCmdContext(f))
+	rootCmd.AddCommand(newCmdDaemon(f))
 	// Add plugin commands
 	rootCmd.AddCommand(newCmdExperimental(f))
 	rootCmd.AddCommand(newManCmd())
 	rootCmd.AddCommand(newCmdBugReport(f))
+	rootCmd.AddCommand(newCmdDiagnose(f))
 	rootCmd.AddCommand(newCmdDebug(f))
 
 	// Deprecated commands, will be removed in the future
@@ -112,6 +131,14 @@ func NewRootCmd(f factory.Factory) *cobra.Command {
 		Aliases:       []string{"q"},
 		Example:       common.RootTPl,
 		PersistentPreRunE: func(cobraCmd *cobra.Command, args []string) error {
+			if len(globalFlags.Context) > 0 {
+				if !contexts.Exists(globalFlags.Context) {
+					return errors.Errorf("context %s not found, add it with: q context add %s", globalFlags.Context, globalFlags.Context)
+				}
+				common.SetContext(globalFlags.Context)
+				// 子进程同样使用该上下文
+				os.Setenv(common.ContextEnv, globalFlags.Context)
+			}
 			if cobraCmd.Annotations != nil {
 				return nil
 			}
@@ -122,7 +149,19 @@ func NewRootCmd(f factory.Factory) *cobra.Command {
 				qlog.SetLevel(logrus.DebugLevel)
 			}
 
+			// 结构化输出时仅保留错误日志, 避免混入 stdout
+			if format, err := flags.OutputFormat(cobraCmd); err == nil && format != output.Table && !globalFlags.Debug {
+				qlog.SetLevel(logrus.ErrorLevel)
+			}
+
+			redact.SetShowSecrets(globalFlags.ShowSecrets)
 			log.StartFileLogging()
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				qlog.Warnf("load config failed, reason: %v", err)
+			}
+			redact.AddSecrets(cfg.Secrets()...)
+			proxy.Setenv(cfg, exnet.LocalIPs()...)
 			return nil
 		},
 	}
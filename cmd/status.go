@@ -10,10 +10,13 @@ import (
 	"context"
 	"os"
 
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/cmd/flags"
 	statussubcmd "github.com/easysoft/qcadmin/cmd/status"
 	"github.com/easysoft/qcadmin/common"
 	"github.com/easysoft/qcadmin/internal/pkg/status"
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/pkg/contexts"
 	"github.com/ergoapi/util/color"
 	"github.com/ergoapi/util/file"
 	"github.com/spf13/cobra"
@@ -24,11 +27,19 @@ func newCmdStatus(f factory.Factory) *cobra.Command {
 	var params = status.K8sStatusOption{
 		Log: log,
 	}
+	var allContexts bool
 	cmd := &cobra.Command{
-		Use:   "status",
-		Short: "Display status",
-		Long:  ``,
+		Use:     "status",
+		Short:   "Display status",
+		Long:    ``,
+		Example: `q status --wait --timeout 10m`,
 		PreRun: func(cmd *cobra.Command, args []string) {
+			if allContexts {
+				return
+			}
+			if len(params.KubeConfig) == 0 {
+				params.KubeConfig = common.GetKubeConfig()
+			}
 			defaultArgs := os.Args
 			if !file.CheckFileExists(params.KubeConfig) {
 				log.Warnf("not found cluster. just run %s init cluster", color.SGreen("%s init", defaultArgs[0]))
@@ -36,6 +47,23 @@ func newCmdStatus(f factory.Factory) *cobra.Command {
 			}
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := flags.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			params.ListOutput = format.String()
+			if allContexts {
+				list := contexts.Status(context.Background(), params)
+				if err := format.Write(os.Stdout, list); err != nil {
+					return err
+				}
+				for _, s := range list {
+					if !s.Healthy {
+						return errors.Errorf("context %s is unhealthy", s.Context)
+					}
+				}
+				return nil
+			}
 			collector, err := status.NewK8sStatusCollector(params)
 			if err != nil {
 				return err
@@ -49,11 +77,13 @@ func newCmdStatus(f factory.Factory) *cobra.Command {
 			return err
 		},
 	}
-	cmd.Flags().StringVarP(&params.KubeConfig, "kubeconfig", "c", common.GetKubeConfig(), "Kubernetes configuration file")
+	cmd.Flags().StringVarP(&params.KubeConfig, "kubeconfig", "c", "", "Kubernetes configuration file, default kubeconfig of current context")
 	cmd.Flags().BoolVar(&params.Wait, "wait", false, "Wait for status to report success (no errors and warnings)")
+	cmd.Flags().DurationVar(&params.WaitDuration, "timeout", common.StatusWaitDuration, "Maximum time to wait for status, exit nonzero when timeout")
 	cmd.Flags().DurationVar(&params.WaitDuration, "wait-duration", common.StatusWaitDuration, "Maximum time to wait for status")
+	cmd.Flags().MarkDeprecated("wait-duration", "use --timeout instead")
 	cmd.Flags().BoolVar(&params.IgnoreWarnings, "ignore-warnings", false, "Ignore warnings when waiting for status to report success")
-	cmd.Flags().StringVarP(&params.ListOutput, "output", "o", "", "prints the output in the specified format. Allowed values: table, json, yaml (default table)")
+	cmd.Flags().BoolVar(&allContexts, "all-contexts", false, "Show status summary of all contexts")
 	cmd.AddCommand(statussubcmd.TopNodeCmd())
 	return cmd
 }
@@ -8,12 +8,12 @@ package storage
 
 import (
 	"context"
-	"os"
 
 	"github.com/easysoft/qcadmin/common"
 	"github.com/easysoft/qcadmin/internal/pkg/k8s"
 	qcexec "github.com/easysoft/qcadmin/internal/pkg/util/exec"
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
 	"github.com/easysoft/qcadmin/internal/pkg/util/log/survey"
 	"github.com/ergoapi/util/color"
 	"github.com/ergoapi/util/exnet"
@@ -105,14 +105,19 @@ func nfs(f factory.Factory) *cobra.Command {
 			return errors.Errorf("nfs server ip or path is empty")
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// TODO check install repo exist
-			if err := qcexec.Command(os.Args[0], "experimental", "helm", "repo-init").Run(); err != nil {
+			hc, err := helm.NewClient(&helm.Config{Namespace: common.DefaultStorageNamespace, Wait: true, Progress: logpkg.Debugf})
+			if err != nil {
+				return errors.Errorf("create helm client failed, reason: %v", err)
+			}
+			if err := hc.InitRepo(common.DefaultHelmRepoName, common.GetChartRepo("")); err != nil {
 				logpkg.Warnf("check helm repo failed: %v", err)
 			}
-			helmargs := []string{"experimental", "helm", "upgrade", "--name", name, "--repo", common.DefaultHelmRepoName, "--chart", "nfs-subdir-external-provisioner", "--namespace", common.DefaultStorageNamespace, "--set", "nfs.server=" + ip, "--set", "nfs.path=" + path, "--set", "storageClass.name=" + name}
-			output, err := qcexec.Command(os.Args[0], helmargs...).CombinedOutput()
+			values, _ := helm.MergeValues([]string{"nfs.server=" + ip, "nfs.path=" + path, "storageClass.name=" + name})
+			logpkg.StartWait("deploy nfs storage class...")
+			_, err = hc.Upgrade(name, common.DefaultHelmRepoName, "nfs-subdir-external-provisioner", "", values)
+			logpkg.StopWait()
 			if err != nil {
-				logpkg.Errorf("upgrade install nfs failed: %s", string(output))
+				logpkg.Errorf("upgrade install nfs failed")
 				return err
 			}
 			logpkg.Infof("install nfs storage class %s (%s:%s) success", color.SGreen(name), color.SGreen(ip), color.SGreen(path))
@@ -8,17 +8,22 @@ package upgrade
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
 
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/cmd/flags"
 	"github.com/easysoft/qcadmin/cmd/version"
 	"github.com/easysoft/qcadmin/common"
 	"github.com/easysoft/qcadmin/internal/app/config"
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
 	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
 	"github.com/easysoft/qcadmin/pkg/selfupdate"
 	"github.com/ergoapi/util/file"
+	"github.com/gosuri/uitable"
 	"github.com/spf13/cobra"
 )
 
@@ -26,22 +31,71 @@ type option struct {
 	log log.Logger
 }
 
+// qCheck q 升级检查结果
+type qCheck struct {
+	Current    string `json:"current" yaml:"current"`
+	Latest     string `json:"latest" yaml:"latest"`
+	Source     string `json:"source" yaml:"source"`
+	CanUpgrade bool   `json:"canUpgrade" yaml:"canUpgrade"`
+}
+
+func (c qCheck) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.AddRow("CURRENT", "LATEST", "SOURCE", "UPGRADE")
+	table.AddRow(c.Current, c.Latest, c.Source, c.CanUpgrade)
+	return output.EncodeTable(out, table)
+}
+
+func (c qCheck) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, c)
+}
+
+func (c qCheck) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, c)
+}
+
 func NewUpgradeQ(f factory.Factory) *cobra.Command {
 	up := option{
 		log: f.GetLog(),
 	}
+	var check bool
 	upq := &cobra.Command{
 		Use:     "q",
 		Aliases: []string{"qcadmin"},
 		Short:   "upgrade qcadmin(q) to the newest version",
 		Args:    cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if check {
+				format, err := flags.OutputFormat(cmd)
+				if err != nil {
+					return err
+				}
+				return up.CheckQcadmin(format)
+			}
 			up.DoQcadmin()
+			return nil
 		},
 	}
+	upq.Flags().BoolVar(&check, "check", false, "only check whether a newer version is available")
 	return upq
 }
 
+// CheckQcadmin 仅检查是否有新版本
+func (up option) CheckQcadmin(format output.Format) error {
+	up.log.StartWait("fetch latest version from remote...")
+	lastVersion, lastType, err := version.PreCheckLatestVersion(up.log)
+	up.log.StopWait()
+	if err != nil {
+		return errors.Wrap(err, "fetch latest version failed")
+	}
+	return format.Write(os.Stdout, qCheck{
+		Current:    common.Version,
+		Latest:     lastVersion,
+		Source:     lastType,
+		CanUpgrade: !(lastVersion == "" || lastVersion == common.Version || strings.Contains(common.Version, lastVersion)),
+	})
+}
+
 func (up option) DoQcadmin() {
 	up.log.StartWait("fetch latest version from remote...")
 	lastVersion, lastType, err := version.PreCheckLatestVersion(up.log)
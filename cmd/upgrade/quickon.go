@@ -8,9 +8,12 @@ package upgrade
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/easysoft/qcadmin/cmd/flags"
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
 	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
 	"github.com/easysoft/qcadmin/pkg/qucheng/upgrade"
 	"github.com/spf13/cobra"
 )
@@ -25,18 +28,38 @@ func NewUpgradeQucheng(f factory.Factory) *cobra.Command {
 	upcmd := &Option{
 		log: f.GetLog(),
 	}
+	var check bool
 	up := &cobra.Command{
 		Use:     "quickon",
 		Aliases: []string{"qc", "qucheng"},
 		Short:   "Upgrades the QuCheng to the newest version",
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if check {
+				format, err := flags.OutputFormat(cmd)
+				if err != nil {
+					return err
+				}
+				return upcmd.Check(format)
+			}
 			return upcmd.Run()
 		},
 	}
+	up.Flags().BoolVar(&check, "check", false, "only check whether components can be upgraded")
 	return up
 }
 
+// Check 仅检查组件是否可升级
+func (cmd *Option) Check(format output.Format) error {
+	cmd.log.StartWait("check update...")
+	qv, err := upgrade.QuchengVersion()
+	cmd.log.StopWait()
+	if err != nil {
+		return err
+	}
+	return format.Write(os.Stdout, qv)
+}
+
 // Run executes the command logic
 func (cmd *Option) Run() error {
 	// Run the upgrade command
@@ -7,6 +7,7 @@
 package cmd
 
 import (
+	"github.com/easysoft/qcadmin/cmd/flags"
 	"github.com/easysoft/qcadmin/cmd/version"
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
 	"github.com/spf13/cobra"
@@ -18,8 +19,12 @@ func newCmdVersion(f factory.Factory) *cobra.Command {
 		Use:   "version",
 		Short: "Show version",
 		Args:  cobra.NoArgs,
-		Run: func(cobraCmd *cobra.Command, args []string) {
-			version.ShowVersion(f.GetLog())
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			format, err := flags.OutputFormat(cobraCmd)
+			if err != nil {
+				return err
+			}
+			return version.ShowVersion(f.GetLog(), format)
 		},
 	}
 }
@@ -8,6 +8,7 @@ package version
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
@@ -22,6 +23,7 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/easysoft/qcadmin/common"
 	logpkg "github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
 	"github.com/easysoft/qcadmin/pkg/qucheng/upgrade"
 	"github.com/ergoapi/util/color"
 	"github.com/ergoapi/util/file"
@@ -87,26 +89,27 @@ type versionGet struct {
 }
 
 type versionInfo struct {
-	Client clientVersion
-	Server serverVersion
+	Client clientVersion `json:"client" yaml:"client"`
+	Server serverVersion `json:"server" yaml:"server"`
 }
 
 type clientVersion struct {
-	Version        string
-	LastVersion    string
-	GoVersion      string
-	GitCommit      string
-	Os             string
-	Arch           string
-	BuildTime      string `json:",omitempty"`
-	Experimental   bool
-	CanUpgrade     bool
-	UpgradeMessage string
+	Version        string `json:"version" yaml:"version"`
+	LastVersion    string `json:"lastVersion,omitempty" yaml:"lastVersion,omitempty"`
+	GoVersion      string `json:"goVersion" yaml:"goVersion"`
+	GitCommit      string `json:"gitCommit" yaml:"gitCommit"`
+	Os             string `json:"os" yaml:"os"`
+	Arch           string `json:"arch" yaml:"arch"`
+	BuildTime      string `json:"buildTime,omitempty" yaml:"buildTime,omitempty"`
+	Experimental   bool   `json:"experimental" yaml:"experimental"`
+	CanUpgrade     bool   `json:"canUpgrade" yaml:"canUpgrade"`
+	UpgradeSource  string `json:"upgradeSource,omitempty" yaml:"upgradeSource,omitempty"`
+	UpgradeMessage string `json:"upgradeMessage,omitempty" yaml:"upgradeMessage,omitempty"`
 }
 
 type serverVersion struct {
-	ServerType common.QuickonType
-	Components *upgrade.Version
+	ServerType common.QuickonType `json:"type,omitempty" yaml:"type,omitempty"`
+	Components *upgrade.Version   `json:"components,omitempty" yaml:"components,omitempty"`
 }
 
 // ServerDeployed returns true when the client could connect to the qucheng
@@ -151,7 +154,8 @@ func checkLatestVersionFromAPI() (string, error) {
 	return lastVersion.Data.Version, nil
 }
 
-func ShowVersion(log logpkg.Logger) {
+// ShowVersion 输出客户端及服务端版本, 检查更新失败时不影响输出
+func ShowVersion(log logpkg.Logger, format output.Format) error {
 	// logo.PrintLogo()
 	if common.Version == "" {
 		common.Version = defaultVersion
@@ -162,11 +166,6 @@ func ShowVersion(log logpkg.Logger) {
 	if common.GitCommitHash == "" {
 		common.GitCommitHash = defaultGitCommitHash
 	}
-	tmpl, err := newVersionTemplate()
-	if err != nil {
-		log.Fatalf("gen version failed, reason: %v", err)
-		return
-	}
 	vd := versionInfo{
 		Client: clientVersion{
 			Version:      common.Version,
@@ -184,16 +183,14 @@ func ShowVersion(log logpkg.Logger) {
 	log.StopWait()
 	if err != nil {
 		log.Debugf("get update message err: %v", err)
-		return
-	}
-	if lastVersion != "" && !strings.Contains(common.Version, lastVersion) {
+	} else if lastVersion != "" && !strings.Contains(common.Version, lastVersion) {
 		nowVersion := gv.MustParse(strings.TrimPrefix(common.Version, "v"))
 		needUpgrade := nowVersion.LessThan(gv.MustParse(lastVersion))
 		if needUpgrade {
 			vd.Client.CanUpgrade = true
 			vd.Client.LastVersion = lastVersion
-			vd.Client.Version = color.SGreen(vd.Client.Version)
-			vd.Client.UpgradeMessage = fmt.Sprintf("Now you can use %s to upgrade cli to the latest version %s by %s mode", color.SGreen("%s upgrade q", os.Args[0]), color.SGreen(lastVersion), color.SGreen(lastType))
+			vd.Client.UpgradeSource = lastType
+			vd.Client.UpgradeMessage = fmt.Sprintf("Now you can use %s upgrade q to upgrade cli to the latest version %s by %s mode", os.Args[0], lastVersion, lastType)
 		}
 	}
 	if file.CheckFileExists(common.GetCustomConfig(common.InitFileName)) {
@@ -206,19 +203,33 @@ func ShowVersion(log logpkg.Logger) {
 			vd.Server.Components = &qv
 		}
 	}
-	if err := prettyPrintVersion(vd, tmpl); err != nil {
-		panic(err)
-	}
+	return format.Write(os.Stdout, vd)
 }
 
-func prettyPrintVersion(vd versionInfo, tmpl *template.Template) error {
-	t := tabwriter.NewWriter(os.Stdout, 20, 1, 1, ' ', 0)
-	err := tmpl.Execute(t, vd)
+func (v versionInfo) WriteTable(out io.Writer) error {
+	tmpl, err := newVersionTemplate()
+	if err != nil {
+		return err
+	}
+	if v.Client.CanUpgrade {
+		v.Client.Version = color.SGreen(v.Client.Version)
+		v.Client.UpgradeMessage = fmt.Sprintf("Now you can use %s to upgrade cli to the latest version %s by %s mode", color.SGreen("%s upgrade q", os.Args[0]), color.SGreen(v.Client.LastVersion), color.SGreen(v.Client.UpgradeSource))
+	}
+	t := tabwriter.NewWriter(out, 20, 1, 1, ' ', 0)
+	err = tmpl.Execute(t, v)
 	t.Write([]byte("\n"))
 	t.Flush()
 	return err
 }
 
+func (v versionInfo) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, v)
+}
+
+func (v versionInfo) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, v)
+}
+
 func newVersionTemplate() (*template.Template, error) {
 	tmpl, err := template.New("version").Parse(versionTpl)
 	return tmpl, errors.Wrap(err, "template parsing error")
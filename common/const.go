@@ -12,6 +12,7 @@ const (
 	FileMode0777 = 0o777
 	FileMode0755 = 0o755
 	FileMode0644 = 0o644
+	FileMode0700 = 0o700
 	FileMode0600 = 0o600
 )
 
@@ -69,6 +70,7 @@ const (
 	K3sBinVersion            = "v1.24.15+k3s1"
 	K3sBinURL                = "https://github.com/k3s-io/k3s/releases/download"
 	K3sAgentEnv              = "/etc/systemd/system/k3s-agent.service.env"
+	K3sServiceEnv            = "/etc/systemd/system/k3s.service.env"
 	K3sKubeConfig            = "/etc/rancher/k3s/k3s.yaml"
 	K3sDefaultDir            = "/var/lib/rancher/k3s"
 	KubeQPS                  = 5.0
@@ -101,6 +103,34 @@ const (
 	MiuiGenerate204URL       = "https://connect.rom.miui.com/generate_204"
 	V2exGenerate204URL       = "https://captive.v2ex.co/generate_204"
 	CloudflareEdgeTraceURL   = "https://www.cloudflare.com/cdn-cgi/trace"
+	DefaultPluginIndexURL    = "https://pkg.qucheng.com/qucheng/cli/plugins/index.json"
+	PluginIndexFileName      = "plugins-index.json"
+	CredentialsFileName      = "credentials"
+	// CredentialsPassphraseEnv 设置后使用口令派生密钥加密凭据, 否则使用机器 id
+	CredentialsPassphraseEnv = "QCADMIN_PASSPHRASE"
+	PluginSecretPrefix       = "qc-plugin-"
+	DefaultTLSSecretName     = "tls-haogs-cn"
+	CustomTLSSecretName      = "tls-quickon-custom"
+	ACMETLSSecretName        = "tls-quickon-acme"
+	SelfSignedTLSSecretName  = "tls-quickon-selfsigned"
+	ACMEIssuerName           = "quickon-acme"
+	DefaultACMEServer        = "https://acme-v02.api.letsencrypt.org/directory"
+)
+
+// 离线安装
+const (
+	HubAppPort         = 32377
+	HubImagePort       = 32378
+	HubAppDataDir      = "/opt/quickon/hub/app/data"
+	K3sAirgapImagesDir = "/var/lib/rancher/k3s/agent/images"
+)
+
+// TLS 证书来源
+const (
+	TLSTypeHaogs      = "haogs"
+	TLSTypeCustom     = "custom"
+	TLSTypeACME       = "acme"
+	TLSTypeSelfSigned = "selfsigned"
 )
 
 const (
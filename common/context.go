@@ -0,0 +1,78 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ergoapi/util/zos"
+)
+
+const (
+	// DefaultContextName 默认上下文, 使用原有的 ~/.qc/config 目录
+	DefaultContextName = "default"
+	// ContextEnv 指定上下文, --context 会写入该变量, 子进程同样生效
+	ContextEnv         = "QCADMIN_CONTEXT"
+	ContextsDir        = "contexts"
+	CurrentContextFile = "current-context"
+)
+
+var (
+	contextMu   sync.RWMutex
+	contextName string
+)
+
+// SetContext 设置当前进程使用的上下文, 为空时恢复默认查找顺序
+func SetContext(name string) {
+	contextMu.Lock()
+	defer contextMu.Unlock()
+	contextName = name
+}
+
+// ContextSelected 是否通过 --context 或 QCADMIN_CONTEXT 显式指定了上下文
+func ContextSelected() bool {
+	contextMu.RLock()
+	name := contextName
+	contextMu.RUnlock()
+	return len(name) > 0 || len(os.Getenv(ContextEnv)) > 0
+}
+
+// CurrentContext 当前上下文, 依次为 --context, QCADMIN_CONTEXT, current-context 文件, default
+func CurrentContext() string {
+	contextMu.RLock()
+	name := contextName
+	contextMu.RUnlock()
+	if len(name) > 0 {
+		return name
+	}
+	if name = os.Getenv(ContextEnv); len(name) > 0 {
+		return name
+	}
+	if b, err := os.ReadFile(GetCurrentContextFile()); err == nil {
+		if name = strings.TrimSpace(string(b)); len(name) > 0 {
+			return name
+		}
+	}
+	return DefaultContextName
+}
+
+// GetCurrentContextFile 记录 q context use 选择的上下文
+func GetCurrentContextFile() string {
+	return fmt.Sprintf("%s/%s/%s", zos.GetHomeDir(), DefaultCfgDir, CurrentContextFile)
+}
+
+// GetContextDir 上下文目录, 包含配置, 凭据及 kubeconfig
+func GetContextDir(name string) string {
+	home := zos.GetHomeDir()
+	if len(name) == 0 || name == DefaultContextName {
+		return fmt.Sprintf("%s/%s", home, DefaultCfgDir)
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", home, DefaultCfgDir, ContextsDir, name)
+}
@@ -36,9 +36,14 @@ func GetQURL() string {
 
 // GetBinURL 获取bin地址
 func GetBinURL(binName string) string {
+	return GetBinURLWithArch(binName, runtime.GOARCH)
+}
+
+// GetBinURLWithArch 获取指定架构的bin地址
+func GetBinURLWithArch(binName, arch string) string {
 	// url := fmt.Sprintf("%s/%s/k3s", K3sBinURL, K3sBinVersion)
 	url := "https://pkg.qucheng.com/qucheng/cli/stable/%s/%s-linux-%s"
-	return fmt.Sprintf(url, binName, binName, runtime.GOARCH)
+	return fmt.Sprintf(url, binName, binName, arch)
 }
 
 func GetDefaultBinDir() string {
@@ -71,6 +76,11 @@ func GetChartRepo(p string) string {
 	return fmt.Sprintf("https://hub.qucheng.com/chartrepo/%s", p)
 }
 
+// GetOfflineChartRepo 离线安装时使用本地 app hub
+func GetOfflineChartRepo() string {
+	return fmt.Sprintf("http://127.0.0.1:%d", HubAppPort)
+}
+
 // GetChannel 获取chartrepo channel地址
 func GetChannel(p string) string {
 	if strings.HasPrefix(p, "test") || strings.HasPrefix(p, "edge") {
@@ -94,8 +104,7 @@ func GetVersion(p string, qt QuickonType) string {
 }
 
 func GetDefaultConfig() string {
-	home := zos.GetHomeDir()
-	return home + "/" + DefaultCfgDir + "/cluster.yaml"
+	return GetContextDir(CurrentContext()) + "/cluster.yaml"
 }
 
 func DefaultKubeConfig() string {
@@ -105,8 +114,7 @@ func DefaultKubeConfig() string {
 }
 
 func DefaultQuickONKubeConfig() string {
-	home := zos.GetHomeDir()
-	d := home + "/" + DefaultCfgDir + "/.kube"
+	d := GetContextDir(CurrentContext()) + "/.kube"
 	// os.MkdirAll(d, FileMode0644)
 	return fmt.Sprintf("%v/config", d)
 }
@@ -114,15 +122,15 @@ func DefaultQuickONKubeConfig() string {
 // GetKubeConfig get kubeconfig
 func GetKubeConfig() string {
 	kubeCfg := DefaultQuickONKubeConfig()
-	if file.CheckFileExists(kubeCfg) {
+	// 非默认上下文不回退到 ~/.kube/config, 避免操作错误的集群
+	if file.CheckFileExists(kubeCfg) || CurrentContext() != DefaultContextName {
 		return kubeCfg
 	}
 	return DefaultKubeConfig()
 }
 
 func GetCustomConfig(name string) string {
-	home := zos.GetHomeDir()
-	return fmt.Sprintf("%s/%s/%s", home, DefaultCfgDir, name)
+	return fmt.Sprintf("%s/%s", GetContextDir(CurrentContext()), name)
 }
 
 func GetAPI(path string) string {
@@ -176,6 +184,11 @@ func GetQuickONName(t QuickonType) string {
 	return fmt.Sprintf("%s-biz", DefaultQuchengName)
 }
 
+// GetPluginIndexCache 获取远程插件索引缓存地址
+func GetPluginIndexCache() string {
+	return fmt.Sprintf("%s/%s", GetDefaultCacheDir(), PluginIndexFileName)
+}
+
 func GetCustomScripts(path string) string {
 	return fmt.Sprintf("%s/%s", GetDefaultDataDir(), path)
 }
@@ -10,8 +10,11 @@ import (
 	"os"
 	"time"
 
+	"github.com/cockroachdb/errors"
 	"github.com/easysoft/qcadmin/common"
 	"github.com/easysoft/qcadmin/internal/pkg/types"
+	"github.com/easysoft/qcadmin/internal/pkg/util/redact"
+	"github.com/easysoft/qcadmin/internal/pkg/util/registries"
 	"github.com/ergoapi/util/exstr"
 	"github.com/ergoapi/util/file"
 	"sigs.k8s.io/yaml"
@@ -28,6 +31,7 @@ type Node struct {
 
 // Config config
 type Config struct {
+	APIVersion      string    `yaml:"apiVersion" json:"apiVersion"`
 	Generated       time.Time `json:"-" yaml:"-"`
 	Global          Global    `yaml:"global" json:"global"`
 	ConsolePassword string    `yaml:"console-password,omitempty" json:"console-password,omitempty"`
@@ -40,6 +44,47 @@ type Config struct {
 	Quickon         Quickon   `yaml:"quickon" json:"quickon"`
 	Install         Install   `yaml:"install,omitempty" json:"install,omitempty"`
 	Storage         Storage   `yaml:"storage,omitempty" json:"storage,omitempty"`
+	Plugin          Plugin    `yaml:"plugin,omitempty" json:"plugin,omitempty"`
+	TLS             TLS       `yaml:"tls,omitempty" json:"tls,omitempty"`
+	Hub             Hub       `yaml:"hub,omitempty" json:"hub,omitempty"`
+	Proxy           Proxy     `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+	// loadErr 读取配置或解密凭据失败, 此时配置不完整, 禁止保存以免覆盖原配置
+	loadErr error
+}
+
+// TLS ingress tls of quickon console
+type TLS struct {
+	Type       string `yaml:"type,omitempty" json:"type,omitempty"`
+	SecretName string `yaml:"secret,omitempty" json:"secret,omitempty"`
+	ACME       *ACME  `yaml:"acme,omitempty" json:"acme,omitempty"`
+}
+
+// ACME cert-manager issuer options
+type ACME struct {
+	Email       string `yaml:"email" json:"email"`
+	Server      string `yaml:"server,omitempty" json:"server,omitempty"`
+	Solver      string `yaml:"solver" json:"solver"`
+	DNSProvider string `yaml:"dnsProvider,omitempty" json:"dnsProvider,omitempty"`
+}
+
+// Hub offline image & chart hub
+type Hub struct {
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+}
+
+// Proxy http(s) proxy of installer, k3s and quickon
+type Proxy struct {
+	HTTPProxy  string `yaml:"http-proxy,omitempty" json:"http-proxy,omitempty"`
+	HTTPSProxy string `yaml:"https-proxy,omitempty" json:"https-proxy,omitempty"`
+	NoProxy    string `yaml:"no-proxy,omitempty" json:"no-proxy,omitempty"`
+}
+
+func (p Proxy) Enabled() bool {
+	return len(p.HTTPProxy) > 0 || len(p.HTTPSProxy) > 0
+}
+
+type Plugin struct {
+	Index string `yaml:"index,omitempty" json:"index,omitempty"`
 }
 
 type Storage struct {
@@ -67,6 +112,8 @@ type Cluster struct {
 	InitNode    string `yaml:"init_node" json:"init_node"`
 	Token       string `yaml:"token" json:"token"`
 	Registry    string `yaml:"registry" json:"registry"`
+	// Registries k3s registries.yaml 中用户配置的 mirror 及认证
+	Registries *registries.Registries `yaml:"registries,omitempty" json:"registries,omitempty"`
 }
 
 type Global struct {
@@ -74,24 +121,69 @@ type Global struct {
 }
 
 type S3Config struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
 }
 
 func NewConfig() *Config {
 	return &Config{
-		Generated: time.Now(),
+		APIVersion: APIVersion,
+		Generated:  time.Now(),
 	}
 }
 
+// LoadConfig 读取配置并回填加密保存的凭据, 旧版配置及明文凭据首次读取时迁移.
+// 配置有误时仍返回尽量解析的配置及错误, 该配置不可保存
 func LoadConfig() (*Config, error) {
 	path := common.GetDefaultConfig()
-	r := new(Config)
-	if file.CheckFileExists(path) {
-		b, _ := os.ReadFile(path)
-		_ = yaml.Unmarshal(b, r)
+	if !file.CheckFileExists(path) {
+		return new(Config), nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		r := new(Config)
+		r.loadErr = errors.Wrapf(err, "read %s failed", path)
+		return r, r.loadErr
+	}
+	r, migrated, decodeErr := Decode(b)
+	if decodeErr != nil {
+		decodeErr = errors.Wrapf(decodeErr, "invalid config %s, run `q config validate` for details", path)
+	}
+	plaintext, credErr := r.mergeCredentials()
+	if decodeErr == nil && credErr == nil && (migrated || plaintext) {
+		return r, r.SaveConfig()
+	}
+	if fi, serr := os.Stat(path); serr == nil && fi.Mode().Perm()&0o077 != 0 {
+		_ = os.Chmod(path, common.FileMode0600)
 	}
-	return r, nil
+	r.loadErr = errors.CombineErrors(decodeErr, credErr)
+	return r, r.loadErr
+}
+
+// ApplyConfig 校验并保存编辑后的配置内容, 未填写的凭据沿用已保存的值
+func ApplyConfig(b []byte) (*Config, error) {
+	r, _, err := Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := r.mergeCredentials(); err != nil {
+		return nil, err
+	}
+	return r, r.SaveConfig()
+}
+
+// mergeCredentials 回填已保存的凭据, 配置中的明文凭据优先, 返回是否存在明文凭据
+func (r *Config) mergeCredentials() (bool, error) {
+	plain := r.extractCredentials()
+	creds, err := loadCredentials()
+	if creds != nil {
+		r.applyCredentials(creds)
+	}
+	r.applyCredentials(plain)
+	return !plain.isEmpty(), err
 }
 
 func LoadTruncateConfig() *Config {
@@ -100,20 +192,46 @@ func LoadTruncateConfig() *Config {
 	if file.CheckFileExists(path) {
 		os.Remove(path)
 	}
+	if file.CheckFileExists(credentialsPath()) {
+		os.Remove(credentialsPath())
+	}
 	return r
 }
 
+// SaveConfig 敏感信息加密保存到 credentials, 配置文件仅当前用户可读写
 func (r *Config) SaveConfig() error {
-	path := common.GetDefaultConfig()
-	b, err := yaml.Marshal(r)
-	if err != nil {
-		return err
+	if r.loadErr != nil {
+		return errors.Wrap(r.loadErr, "config was not loaded completely, refuse to save")
 	}
-	err = os.WriteFile(path, b, common.FileMode0644)
+	c := *r
+	c.APIVersion = APIVersion
+	creds := c.extractCredentials()
+	if err := saveCredentials(creds); err != nil {
+		return errors.Wrap(err, "save credentials failed")
+	}
+	b, err := yaml.Marshal(&c)
 	if err != nil {
 		return err
 	}
-	return nil
+	return writePrivateFile(common.GetDefaultConfig(), b)
+}
+
+// Secrets 配置中的敏感值, 用于日志等文本脱敏
+func (r *Config) Secrets() []string {
+	secrets := []string{r.ConsolePassword, r.APIToken, r.S3.Password, r.Cluster.Token, r.Global.SSH.Passwd, r.Global.SSH.PkPasswd}
+	if r.Cluster.Registries != nil {
+		for _, c := range r.Cluster.Registries.Configs {
+			if c.Auth != nil {
+				secrets = append(secrets, c.Auth.Password)
+			}
+		}
+	}
+	return secrets
+}
+
+// Redacted 脱敏后的配置, 用于 json/yaml 输出, --show-secrets 时不脱敏
+func (r *Config) Redacted() (interface{}, error) {
+	return redact.OutputObject(r)
 }
 
 func (r *Config) GetNodes() []Node {
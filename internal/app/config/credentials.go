@@ -0,0 +1,260 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/util/registries"
+	"github.com/ergoapi/util/file"
+	"github.com/ergoapi/util/zos"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	kdfMachine    = "machine-id"
+	kdfPassphrase = "passphrase"
+)
+
+// Credentials 配置中的敏感信息, 单独加密保存, 不写入 cluster.yaml
+type Credentials struct {
+	ConsolePassword   string            `json:"console-password,omitempty"`
+	APIToken          string            `json:"api-token,omitempty"`
+	S3Password        string            `json:"s3-password,omitempty"`
+	ClusterToken      string            `json:"cluster-token,omitempty"`
+	DB                string            `json:"db,omitempty"`
+	SSHPasswd         string            `json:"ssh-passwd,omitempty"`
+	SSHPkData         string            `json:"ssh-pk-data,omitempty"`
+	SSHPkPasswd       string            `json:"ssh-pk-passwd,omitempty"`
+	RegistryPasswords map[string]string `json:"registry-passwords,omitempty"`
+}
+
+// sealedCredentials 加密后的凭据文件
+type sealedCredentials struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+func (c *Credentials) isEmpty() bool {
+	if len(c.RegistryPasswords) > 0 {
+		return false
+	}
+	for _, v := range []string{c.ConsolePassword, c.APIToken, c.S3Password, c.ClusterToken, c.DB, c.SSHPasswd, c.SSHPkData, c.SSHPkPasswd} {
+		if len(v) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// extractCredentials 移出敏感信息, registries 会复制后修改, 不影响共享的指针
+func (r *Config) extractCredentials() *Credentials {
+	c := &Credentials{
+		ConsolePassword: r.ConsolePassword,
+		APIToken:        r.APIToken,
+		S3Password:      r.S3.Password,
+		ClusterToken:    r.Cluster.Token,
+		SSHPasswd:       r.Global.SSH.Passwd,
+		SSHPkData:       r.Global.SSH.PkData,
+		SSHPkPasswd:     r.Global.SSH.PkPasswd,
+	}
+	r.ConsolePassword, r.APIToken, r.S3.Password, r.Cluster.Token = "", "", "", ""
+	r.Global.SSH.Passwd, r.Global.SSH.PkData, r.Global.SSH.PkPasswd = "", "", ""
+	// 外部数据库 dsn 含密码
+	if strings.Contains(r.DB, "@") {
+		c.DB, r.DB = r.DB, ""
+	}
+	if r.Cluster.Registries != nil && len(r.Cluster.Registries.Configs) > 0 {
+		regs := *r.Cluster.Registries
+		regs.Configs = map[string]registries.RegistryConfig{}
+		for name, rc := range r.Cluster.Registries.Configs {
+			if rc.Auth != nil && len(rc.Auth.Password) > 0 {
+				if c.RegistryPasswords == nil {
+					c.RegistryPasswords = map[string]string{}
+				}
+				c.RegistryPasswords[name] = rc.Auth.Password
+				auth := *rc.Auth
+				auth.Password = ""
+				rc.Auth = &auth
+			}
+			regs.Configs[name] = rc
+		}
+		r.Cluster.Registries = &regs
+	}
+	return c
+}
+
+// applyCredentials 回填敏感信息, 仅覆盖非空字段
+func (r *Config) applyCredentials(c *Credentials) {
+	set := func(dst *string, v string) {
+		if len(v) > 0 {
+			*dst = v
+		}
+	}
+	set(&r.ConsolePassword, c.ConsolePassword)
+	set(&r.APIToken, c.APIToken)
+	set(&r.S3.Password, c.S3Password)
+	set(&r.Cluster.Token, c.ClusterToken)
+	set(&r.DB, c.DB)
+	set(&r.Global.SSH.Passwd, c.SSHPasswd)
+	set(&r.Global.SSH.PkData, c.SSHPkData)
+	set(&r.Global.SSH.PkPasswd, c.SSHPkPasswd)
+	if r.Cluster.Registries == nil {
+		return
+	}
+	for name, password := range c.RegistryPasswords {
+		if rc, ok := r.Cluster.Registries.Configs[name]; ok && rc.Auth != nil {
+			rc.Auth.Password = password
+		}
+	}
+}
+
+func credentialsPath() string {
+	return common.GetCustomConfig(common.CredentialsFileName)
+}
+
+// loadCredentials 读取并解密凭据, 文件不存在时返回 nil
+func loadCredentials() (*Credentials, error) {
+	path := credentialsPath()
+	if !file.CheckFileExists(path) {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sealed sealedCredentials
+	if err := json.Unmarshal(b, &sealed); err != nil {
+		return nil, errors.Wrapf(err, "parse %s failed", path)
+	}
+	data, err := open(&sealed)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Credentials)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, errors.Wrap(err, "parse credentials failed")
+	}
+	return c, nil
+}
+
+// saveCredentials 加密保存凭据, 无凭据时删除文件.
+// 已存在但无法解密的凭据文件不会被覆盖或删除
+func saveCredentials(c *Credentials) error {
+	path := credentialsPath()
+	if _, err := loadCredentials(); err != nil {
+		return errors.Wrapf(err, "refuse to overwrite %s, remove it manually to reset credentials", path)
+	}
+	if c.isEmpty() {
+		if file.CheckFileExists(path) {
+			return os.Remove(path)
+		}
+		return nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(data)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(path, b)
+}
+
+// writePrivateFile 写入仅当前用户可读写的文件, 已存在的文件同样收紧权限
+func writePrivateFile(path string, b []byte) error {
+	if err := os.WriteFile(path, b, common.FileMode0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, common.FileMode0600)
+}
+
+// deriveKey 设置口令时使用口令, 否则使用机器 id 派生密钥
+func deriveKey(kdf string, salt []byte) ([]byte, error) {
+	var secret string
+	switch kdf {
+	case kdfPassphrase:
+		secret = os.Getenv(common.CredentialsPassphraseEnv)
+		if len(secret) == 0 {
+			return nil, errors.Errorf("credentials are encrypted with passphrase, please set %s", common.CredentialsPassphraseEnv)
+		}
+	case kdfMachine:
+		secret = machineID()
+	default:
+		return nil, errors.Errorf("unsupported credentials kdf %s", kdf)
+	}
+	return scrypt.Key([]byte(secret), salt, 1<<15, 8, 1, 32)
+}
+
+func seal(data []byte) (*sealedCredentials, error) {
+	sealed := &sealedCredentials{Version: 1, KDF: kdfMachine, Salt: make([]byte, 16)}
+	if len(os.Getenv(common.CredentialsPassphraseEnv)) > 0 {
+		sealed.KDF = kdfPassphrase
+	}
+	if _, err := rand.Read(sealed.Salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(sealed.KDF, sealed.Salt)
+	if err != nil {
+		return nil, err
+	}
+	sealed.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(sealed.Nonce); err != nil {
+		return nil, err
+	}
+	sealed.Data = gcm.Seal(nil, sealed.Nonce, data, nil)
+	return sealed, nil
+}
+
+func open(sealed *sealedCredentials) ([]byte, error) {
+	gcm, err := newGCM(sealed.KDF, sealed.Salt)
+	if err != nil {
+		return nil, err
+	}
+	data, err := gcm.Open(nil, sealed.Nonce, sealed.Data, nil)
+	if err != nil {
+		return nil, errors.Errorf("decrypt credentials failed, machine id changed or wrong %s", common.CredentialsPassphraseEnv)
+	}
+	return data, nil
+}
+
+func newGCM(kdf string, salt []byte) (cipher.AEAD, error) {
+	key, err := deriveKey(kdf, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// machineID systemd/dbus 机器 id, 不存在时使用主机名及家目录
+func machineID() string {
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if b, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(b))) > 0 {
+			return strings.TrimSpace(string(b))
+		}
+	}
+	return zos.GetHostname() + zos.GetHomeDir()
+}
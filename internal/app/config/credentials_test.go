@@ -0,0 +1,95 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/util/registries"
+)
+
+// TestMain 家目录在进程内会被缓存, 测试共用临时家目录, 以上下文隔离
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "qcadmin-config")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", home)
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+// useTestContext 切换到独立的上下文目录
+func useTestContext(t *testing.T, name string) string {
+	t.Setenv(common.ContextEnv, name)
+	dir := common.GetContextDir(name)
+	if err := os.MkdirAll(dir, common.FileMode0700); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCredentialsRoundTrip(t *testing.T) {
+	r := &Config{ConsolePassword: "console-pass", DB: "mysql://root:pass@db:3306/q"}
+	r.Cluster.Registries = &registries.Registries{}
+	r.Cluster.Registries.SetAuth("hub.example.com", "admin", "registry-pass")
+
+	c := *r
+	creds := c.extractCredentials()
+	if c.ConsolePassword != "" || c.DB != "" || c.Cluster.Registries.Configs["hub.example.com"].Auth.Password != "" {
+		t.Fatalf("secrets not extracted: %+v", c)
+	}
+	if r.Cluster.Registries.Configs["hub.example.com"].Auth.Password != "registry-pass" {
+		t.Fatal("extract modified the original registries")
+	}
+
+	sealed, err := seal([]byte("console-pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := open(sealed)
+	if err != nil || string(data) != "console-pass" {
+		t.Fatalf("open = %q, %v", data, err)
+	}
+
+	c.applyCredentials(creds)
+	if c.ConsolePassword != "console-pass" || c.DB != r.DB || c.Cluster.Registries.Configs["hub.example.com"].Auth.Password != "registry-pass" {
+		t.Fatalf("secrets not applied: %+v", c)
+	}
+}
+
+func TestSaveConfigAfterDecryptFailure(t *testing.T) {
+	useTestContext(t, "decrypt")
+	t.Setenv(common.CredentialsPassphraseEnv, "passphrase")
+	if err := (&Config{Domain: "demo.example.com", ConsolePassword: "console-pass"}).SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := os.ReadFile(credentialsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(common.CredentialsPassphraseEnv, "")
+	cfg, err := LoadConfig()
+	if err == nil {
+		t.Fatal("expect decrypt error without passphrase")
+	}
+	cfg.Domain = "other.example.com"
+	if err := cfg.SaveConfig(); err == nil {
+		t.Fatal("expect save refused after load error")
+	}
+	if err := saveCredentials(&Credentials{}); err == nil {
+		t.Fatal("expect undecryptable credentials not removed")
+	}
+	if b, _ := os.ReadFile(credentialsPath()); !bytes.Equal(b, sealed) {
+		t.Fatal("credentials file changed")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/cockroachdb/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// APIVersion 当前配置版本, 旧版配置无 apiVersion 字段
+const APIVersion = "qcadmin/v1"
+
+// migration 将 from 版本的原始配置升级到 to 版本
+type migration struct {
+	from    string
+	to      string
+	migrate func(raw map[string]interface{}) error
+}
+
+// migrations 按版本顺序注册, 新增版本时追加
+var migrations = []migration{
+	{from: "", to: APIVersion, migrate: migrateLegacy},
+}
+
+// migrateLegacy 早期配置的 token, master, worker 位于顶层, 移入 cluster
+func migrateLegacy(raw map[string]interface{}) error {
+	cluster, ok := raw["cluster"].(map[string]interface{})
+	if !ok {
+		if raw["cluster"] != nil {
+			return errors.New("cluster should be a map")
+		}
+		cluster = map[string]interface{}{}
+	}
+	for _, key := range []string{"token", "master", "worker"} {
+		v, exist := raw[key]
+		if !exist {
+			continue
+		}
+		if _, set := cluster[key]; !set && v != nil {
+			cluster[key] = v
+		}
+		delete(raw, key)
+	}
+	raw["cluster"] = cluster
+	return nil
+}
+
+// migrate 依次执行迁移, 返回是否发生迁移
+func migrate(raw map[string]interface{}) (bool, error) {
+	version, _ := raw["apiVersion"].(string)
+	migrated := false
+	for _, m := range migrations {
+		if m.from != version {
+			continue
+		}
+		if err := m.migrate(raw); err != nil {
+			return migrated, errors.Wrapf(err, "migrate config from %q to %s failed", m.from, m.to)
+		}
+		version = m.to
+		raw["apiVersion"] = version
+		migrated = true
+	}
+	if version != APIVersion {
+		return migrated, errors.Errorf("unsupported apiVersion %q, expected %s", version, APIVersion)
+	}
+	return migrated, nil
+}
+
+// Decode 解析配置, 执行版本迁移并严格校验字段.
+// 严格解析失败时仍返回尽量解析的配置及错误, 便于调用方继续使用
+func Decode(b []byte) (*Config, bool, error) {
+	r := new(Config)
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return r, false, errors.Wrap(err, "invalid yaml")
+	}
+	if raw == nil {
+		raw = map[string]interface{}{}
+	}
+	migrated, err := migrate(raw)
+	if err != nil {
+		return r, migrated, err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return r, migrated, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(r); err != nil {
+		r = new(Config)
+		_ = json.Unmarshal(data, r)
+		return r, migrated, errors.Wrap(err, "decode config failed")
+	}
+	return r, migrated, nil
+}
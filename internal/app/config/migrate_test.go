@@ -0,0 +1,63 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/easysoft/qcadmin/common"
+)
+
+func TestDecode(t *testing.T) {
+	legacy := []byte(`db: sqlite
+token: abcd
+master:
+- name: master
+  host: 192.168.1.10
+`)
+	cfg, migrated, err := Decode(legacy)
+	if err != nil || !migrated {
+		t.Fatalf("decode legacy = %v, migrated %v", err, migrated)
+	}
+	if cfg.APIVersion != APIVersion || cfg.Cluster.Token != "abcd" || len(cfg.Cluster.Master) != 1 {
+		t.Fatalf("legacy not migrated: %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Decode([]byte("apiVersion: " + APIVersion + "\ndomian: demo.example.com\n")); err == nil {
+		t.Fatal("expect unknown field error")
+	}
+	if _, _, err := Decode([]byte("apiVersion: qcadmin/v9\n")); err == nil {
+		t.Fatal("expect unsupported apiVersion error")
+	}
+}
+
+func TestSaveConfigAfterDecodeFailure(t *testing.T) {
+	dir := useTestContext(t, "decode")
+	for _, content := range []string{"apiVersion: qcadmin/v9\ndomain: demo.example.com\n", "domain: [demo\n"} {
+		path := filepath.Join(dir, "cluster.yaml")
+		if err := os.WriteFile(path, []byte(content), common.FileMode0600); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadConfig()
+		if err == nil {
+			t.Fatalf("expect load error for %q", content)
+		}
+		cfg.Plugin.Index = "https://example.com/index.yaml"
+		if err := cfg.SaveConfig(); err == nil {
+			t.Fatalf("expect save refused for %q", content)
+		}
+		if b, _ := os.ReadFile(path); !bytes.Equal(b, []byte(content)) {
+			t.Fatalf("config overwritten: %q", b)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+)
+
+// Validate 校验配置取值, 返回全部问题
+func (r *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	if r.APIVersion != APIVersion {
+		add("apiVersion: unsupported %q, expected %s", r.APIVersion, APIVersion)
+	}
+	switch r.Quickon.Type {
+	case "", common.QuickonOSSType, common.QuickonEEType:
+	default:
+		add("quickon.type: unsupported %q, allowed: %s, %s", r.Quickon.Type, common.QuickonOSSType, common.QuickonEEType)
+	}
+	hosts := map[string]bool{}
+	for i, node := range r.GetNodes() {
+		if net.ParseIP(node.Host) == nil {
+			add("cluster node %d: host %q is not a valid ip", i, node.Host)
+			continue
+		}
+		if hosts[node.Host] {
+			add("cluster node %d: host %s is duplicated", i, node.Host)
+		}
+		hosts[node.Host] = true
+	}
+	if len(r.Cluster.InitNode) > 0 && net.ParseIP(r.Cluster.InitNode) == nil {
+		add("cluster.init_node: %q is not a valid ip", r.Cluster.InitNode)
+	}
+	for _, cidr := range [][2]string{{"cluster.pod-cidr", r.Cluster.PodCIDR}, {"cluster.svc-cidr", r.Cluster.ServiceCIDR}} {
+		if len(cidr[1]) == 0 {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(cidr[1]); err != nil {
+			add("%s: %q is not a valid cidr", cidr[0], cidr[1])
+		}
+	}
+	switch r.TLS.Type {
+	case "", common.TLSTypeHaogs, common.TLSTypeCustom, common.TLSTypeSelfSigned:
+	case common.TLSTypeACME:
+		if r.TLS.ACME == nil || len(r.TLS.ACME.Email) == 0 {
+			add("tls.acme.email: required when tls.type is %s", common.TLSTypeACME)
+		}
+	default:
+		add("tls.type: unsupported %q", r.TLS.Type)
+	}
+	for _, proxy := range [][2]string{{"proxy.http-proxy", r.Proxy.HTTPProxy}, {"proxy.https-proxy", r.Proxy.HTTPSProxy}} {
+		if len(proxy[1]) == 0 {
+			continue
+		}
+		if u, err := url.Parse(proxy[1]); err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+			add("%s: %q is not a valid url", proxy[0], proxy[1])
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.Errorf("invalid config:\n  - %s", strings.Join(problems, "\n  - "))
+}
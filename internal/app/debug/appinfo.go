@@ -0,0 +1,87 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/gosuri/uitable"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AppInfo 应用信息及 release 下的 pod
+type AppInfo struct {
+	App  AppData  `json:"app" yaml:"app"`
+	Pods []AppPod `json:"pods" yaml:"pods"`
+}
+
+// AppPod 应用 pod 状态
+type AppPod struct {
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Name      string `json:"name" yaml:"name"`
+	Phase     string `json:"phase" yaml:"phase"`
+	Ready     string `json:"ready" yaml:"ready"`
+	Restarts  int32  `json:"restarts" yaml:"restarts"`
+	Node      string `json:"node,omitempty" yaml:"node,omitempty"`
+	IP        string `json:"ip,omitempty" yaml:"ip,omitempty"`
+}
+
+// GetAppInfo 按 release 标签查询应用 pod
+func GetAppInfo(ctx context.Context, app *AppData) (*AppInfo, error) {
+	client, err := k8s.NewSimpleClient()
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.ListPods(ctx, "", metav1.ListOptions{LabelSelector: "release=" + app.K8Name})
+	if err != nil {
+		return nil, err
+	}
+	info := &AppInfo{App: *app, Pods: []AppPod{}}
+	for _, pod := range pods.Items {
+		ready, restarts := 0, int32(0)
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Ready {
+				ready++
+			}
+			restarts += cs.RestartCount
+		}
+		info.Pods = append(info.Pods, AppPod{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			Phase:     string(pod.Status.Phase),
+			Ready:     fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers)),
+			Restarts:  restarts,
+			Node:      pod.Spec.NodeName,
+			IP:        pod.Status.PodIP,
+		})
+	}
+	return info, nil
+}
+
+func (i *AppInfo) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.AddRow("APP", "VERSION", "STATUS", "RELEASE", "DOMAIN")
+	table.AddRow(i.App.Name, i.App.Version, i.App.Status, i.App.K8Name, i.App.Domain)
+	table.AddRow("")
+	table.AddRow("NAMESPACE", "POD", "READY", "STATUS", "RESTARTS", "NODE")
+	for _, p := range i.Pods {
+		table.AddRow(p.Namespace, p.Name, p.Ready, p.Phase, p.Restarts, p.Node)
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (i *AppInfo) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, i)
+}
+
+func (i *AppInfo) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, i)
+}
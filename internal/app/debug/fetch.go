@@ -54,7 +54,10 @@ func GetNameByURL(url string, debug, useip bool) (*AppData, error) {
 	}
 	key := k[2]
 
-	cfg, _ := config.LoadConfig()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
 	if cfg.APIToken == "" {
 		k8sClient, err := k8s.NewSimpleClient()
 		if err != nil {
@@ -8,6 +8,7 @@ package helm
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
 	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
@@ -32,42 +33,16 @@ func EmbedCommand(f factory.Factory) *cobra.Command {
 }
 
 func repoInit(f factory.Factory) *cobra.Command {
-	logpkg := f.GetLog()
 	var name, url string
 	helm := &cobra.Command{
 		Use:   "repo-init",
 		Short: "init helm repo",
-		PostRunE: func(cmd *cobra.Command, args []string) error {
-			logpkg.Debug("update helm repo")
-			hc, err := helm.NewClient(&helm.Config{Namespace: ""})
-			if err != nil {
-				return fmt.Errorf("helm create go client err: %v", err)
-			}
-			return hc.UpdateRepo()
-		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			hc, err := helm.NewClient(&helm.Config{Namespace: ""})
 			if err != nil {
 				return fmt.Errorf("helm create go client err: %v", err)
 			}
-			repos, _ := hc.ListRepo()
-			if len(repos) == 0 {
-				logpkg.Debug("repo count 0, will add helm repo")
-				return hc.AddRepo(name, url, "", "")
-			}
-			notexist := true
-			for _, r := range repos {
-				if r.Name == name {
-					notexist = false
-					break
-				}
-			}
-			if notexist {
-				logpkg.Debugf("not found repo %s, will add helm repo", name)
-				return hc.AddRepo(name, url, "", "")
-			}
-			logpkg.Debugf("found repo %s, will update helm repo", name)
-			return nil
+			return hc.InitRepo(name, url)
 		},
 	}
 	helm.Flags().StringVarP(&name, "name", "n", "install", "repo name")
@@ -160,7 +135,9 @@ func repoDel(f factory.Factory) *cobra.Command {
 
 func chartUpgrade(f factory.Factory) *cobra.Command {
 	var ns, name, repoName, chartName, chartVersion string
-	var p []string
+	var p, files []string
+	var wait, atomic bool
+	var timeout time.Duration
 	helm := &cobra.Command{
 		Use:   "upgrade",
 		Short: "upgrade a release",
@@ -174,11 +151,14 @@ func chartUpgrade(f factory.Factory) *cobra.Command {
 			if ns == "" {
 				ns = "default"
 			}
-			hc, err := helm.NewClient(&helm.Config{Namespace: ns})
+			hc, err := helm.NewClient(&helm.Config{Namespace: ns, Wait: wait, Atomic: atomic, Timeout: timeout, Progress: f.GetLog().Debugf})
 			if err != nil {
 				return fmt.Errorf("helm create go client err: %v", err)
 			}
-			values, _ := helm.MergeValues(p)
+			values, err := helm.ParseValues(files, p)
+			if err != nil {
+				return err
+			}
 			_, err = hc.Upgrade(name, repoName, chartName, chartVersion, values)
 			return err
 		},
@@ -189,6 +169,10 @@ func chartUpgrade(f factory.Factory) *cobra.Command {
 	helm.Flags().StringVar(&chartName, "chart", "", "chart name")
 	helm.Flags().StringVar(&chartVersion, "version", "", "chart version")
 	helm.Flags().StringArrayVar(&p, "set", []string{}, "set values on the command line (e.g. '--set key1=value1,key2=value2')")
+	helm.Flags().StringArrayVarP(&files, "values", "f", []string{}, "specify values in a YAML file (can specify multiple)")
+	helm.Flags().BoolVar(&wait, "wait", false, "wait until all resources are ready")
+	helm.Flags().BoolVar(&atomic, "atomic", false, "roll back changes on failed upgrade, implies --wait")
+	helm.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "time to wait for any individual kubernetes operation")
 	return helm
 }
 
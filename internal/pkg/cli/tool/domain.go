@@ -45,11 +45,13 @@ func domainClean(f factory.Factory) *cobra.Command {
 		Short:  "clean domain",
 		Hidden: true,
 		Run: func(cmd *cobra.Command, args []string) {
-			cfg, _ := config.LoadConfig()
-			if cfg != nil {
-				if !kutil.IsLegalDomain(cfg.Domain) {
-					return
-				}
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				f.GetLog().Errorf("load config failed, reason: %v", err)
+				return
+			}
+			if !kutil.IsLegalDomain(cfg.Domain) {
+				return
 			}
 			secretKey := cfg.Cluster.ID
 			if len(secretKey) == 0 {
@@ -60,7 +62,9 @@ func domainClean(f factory.Factory) *cobra.Command {
 				}
 				secretKey = string(ns.ObjectMeta.GetUID())
 				cfg.Cluster.ID = secretKey
-				cfg.SaveConfig()
+				if err := cfg.SaveConfig(); err != nil {
+					f.GetLog().Warnf("save cluster id failed, reason: %v", err)
+				}
 			}
 			// TODO 获取subdomain, maindomain
 			subDomain, mainDomain := kutil.SplitDomain(cfg.Domain)
@@ -90,11 +94,12 @@ func domainAdd(f factory.Factory) *cobra.Command {
 		Hidden: true,
 		Run: func(cmd *cobra.Command, args []string) {
 			// load config
-			domain := ""
-			cfg, _ := config.LoadConfig()
-			if cfg != nil {
-				domain = cfg.Domain
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				log.Errorf("load config failed, reason: %v", err)
+				return
 			}
+			domain := cfg.Domain
 			if len(domain) > 0 {
 				return
 			}
@@ -119,7 +124,10 @@ func domainAdd(f factory.Factory) *cobra.Command {
 				cfg.Domain = customdomain
 			}
 			// save config
-			cfg.SaveConfig()
+			if err := cfg.SaveConfig(); err != nil {
+				log.Errorf("save config failed, reason: %v", err)
+				return
+			}
 			// upgrade qucheng
 			helmClient, _ := helm.NewClient(&helm.Config{Namespace: common.GetDefaultSystemNamespace(true)})
 			if err := helmClient.UpdateRepo(); err != nil {
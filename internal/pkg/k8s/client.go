@@ -31,6 +31,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
@@ -60,8 +61,16 @@ type Client struct {
 	QClient          *quchengclientset.Clientset
 }
 
+// envKubeConfig 显式指定上下文时忽略 $KUBECONFIG, 与 helm 使用同一集群
+func envKubeConfig() string {
+	if common.ContextSelected() {
+		return ""
+	}
+	return os.Getenv("KUBECONFIG")
+}
+
 func NewSimpleQClient() (*Client, error) {
-	kubeconfig := os.Getenv("KUBECONFIG")
+	kubeconfig := envKubeConfig()
 	if kubeconfig == "" {
 		kubeconfig = common.GetKubeConfig()
 	}
@@ -85,7 +94,7 @@ func NewSimpleQClient() (*Client, error) {
 }
 
 func NewSimpleClient(kubecfg ...string) (*Client, error) {
-	kubeconfig := os.Getenv("KUBECONFIG")
+	kubeconfig := envKubeConfig()
 	if kubeconfig == "" {
 		if len(kubecfg) > 0 {
 			kubeconfig = kubecfg[0]
@@ -109,7 +118,7 @@ func NewSimpleClient(kubecfg ...string) (*Client, error) {
 
 func NewClient(contextName, kubeconfig string) (*Client, error) {
 	if kubeconfig == "" {
-		kubeconfig = os.Getenv("KUBECONFIG")
+		kubeconfig = envKubeConfig()
 		if kubeconfig == "" {
 			kubeconfig = common.GetKubeConfig()
 		}
@@ -427,6 +436,14 @@ func (c *Client) GetDeployment(ctx context.Context, namespace, name string, opts
 	return c.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, opts)
 }
 
+func (c *Client) ListDeployments(ctx context.Context, namespace string, o metav1.ListOptions) (*appsv1.DeploymentList, error) {
+	return c.Clientset.AppsV1().Deployments(namespace).List(ctx, o)
+}
+
+func (c *Client) ListStatefulSets(ctx context.Context, namespace string, o metav1.ListOptions) (*appsv1.StatefulSetList, error) {
+	return c.Clientset.AppsV1().StatefulSets(namespace).List(ctx, o)
+}
+
 func (c *Client) DeleteDeployment(ctx context.Context, namespace, name string, opts metav1.DeleteOptions) error {
 	return c.Clientset.AppsV1().Deployments(namespace).Delete(ctx, name, opts)
 }
@@ -509,6 +526,10 @@ func (c *Client) ListEvents(ctx context.Context, o metav1.ListOptions) (*corev1.
 	return c.Clientset.CoreV1().Events(corev1.NamespaceAll).List(ctx, o)
 }
 
+func (c *Client) WatchEvents(ctx context.Context, o metav1.ListOptions) (watch.Interface, error) {
+	return c.Clientset.CoreV1().Events(corev1.NamespaceAll).Watch(ctx, o)
+}
+
 func (c *Client) DeletePod(ctx context.Context, namespace, name string, opts metav1.DeleteOptions) error {
 	return c.Clientset.CoreV1().Pods(namespace).Delete(ctx, name, opts)
 }
@@ -653,6 +674,14 @@ func (c *Client) ExecPodWithTTY(ctx context.Context, namespace, podName, contain
 	return err
 }
 
+func (c *Client) ListIngresses(ctx context.Context, namespace string, opts metav1.ListOptions) (*networkingv1.IngressList, error) {
+	return c.Clientset.NetworkingV1().Ingresses(namespace).List(ctx, opts)
+}
+
+func (c *Client) UpdateIngress(ctx context.Context, ingress *networkingv1.Ingress, opts metav1.UpdateOptions) (*networkingv1.Ingress, error) {
+	return c.Clientset.NetworkingV1().Ingresses(ingress.Namespace).Update(ctx, ingress, opts)
+}
+
 func (c *Client) CreateIngressClass(ctx context.Context, ingressClass *networkingv1.IngressClass, opts metav1.CreateOptions) (*networkingv1.IngressClass, error) {
 	return c.Clientset.NetworkingV1().IngressClasses().Create(ctx, ingressClass, opts)
 }
@@ -0,0 +1,75 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package k8s
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/easysoft/qcadmin/common"
+)
+
+func writeKubeConfig(t *testing.T, path, server string) {
+	content := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: c
+  cluster:
+    server: %s
+contexts:
+- name: c
+  context:
+    cluster: c
+current-context: c
+`, server)
+	if err := os.MkdirAll(filepath.Dir(path), common.FileMode0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), common.FileMode0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKubeConfigPrecedence(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	env := filepath.Join(t.TempDir(), "config")
+	writeKubeConfig(t, env, "https://staging:6443")
+	writeKubeConfig(t, filepath.Join(common.GetContextDir("prod"), ".kube", "config"), "https://prod:6443")
+	writeKubeConfig(t, filepath.Join(common.GetContextDir(common.DefaultContextName), ".kube", "config"), "https://default:6443")
+	t.Setenv("KUBECONFIG", env)
+
+	tests := []struct {
+		name    string
+		flag    string
+		envName string
+		want    string
+	}{
+		{name: "kubeconfig env without context", want: "https://staging:6443"},
+		{name: "context flag", flag: "prod", want: "https://prod:6443"},
+		{name: "context env", envName: "prod", want: "https://prod:6443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			common.SetContext(tt.flag)
+			defer common.SetContext("")
+			t.Setenv(common.ContextEnv, tt.envName)
+			simple, err := NewSimpleClient(common.GetKubeConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := NewClient("", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if simple.Config.Host != tt.want || c.Config.Host != tt.want {
+				t.Fatalf("host = %s, %s, want %s", simple.Config.Host, c.Config.Host, tt.want)
+			}
+		})
+	}
+}
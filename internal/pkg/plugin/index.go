@@ -0,0 +1,213 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	gv "github.com/Masterminds/semver/v3"
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/ergoapi/util/file"
+	"github.com/imroc/req/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const indexAPIVersion = "v1"
+
+// parseIndex 解析插件索引, 兼容旧版仅包含插件数组的格式
+func parseIndex(content []byte) (*Index, error) {
+	idx := &Index{}
+	trimmed := strings.TrimSpace(string(content))
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(content, &idx.Plugins); err != nil {
+			return nil, err
+		}
+		idx.APIVersion = indexAPIVersion
+		return idx, nil
+	}
+	if err := json.Unmarshal(content, idx); err != nil {
+		return nil, err
+	}
+	if idx.APIVersion != "" && idx.APIVersion != indexAPIVersion {
+		return nil, errors.Errorf("unsupported plugin index version %s", idx.APIVersion)
+	}
+	return idx, nil
+}
+
+func loadIndexFile(path string) (*Index, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := parseIndex(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unmarshal plugin index %s failed", path)
+	}
+	return idx, nil
+}
+
+func localIndexPath() string {
+	return fmt.Sprintf("%s/hack/manifests/plugins/plugins.json", common.GetDefaultDataDir())
+}
+
+// LoadIndex 加载插件索引, 远程索引缓存优先, 本地内置插件作为补充
+func LoadIndex() (*Index, error) {
+	log := log.GetInstance()
+	log.Debug("load local plugin config from", localIndexPath())
+	local, err := loadIndexFile(localIndexPath())
+	if err != nil {
+		return nil, err
+	}
+	cache := common.GetPluginIndexCache()
+	if !file.CheckFileExists(cache) {
+		return local, nil
+	}
+	log.Debug("load cached plugin index from", cache)
+	remote, err := loadIndexFile(cache)
+	if err != nil {
+		log.Warnf("load cached plugin index failed, fallback to local: %v", err)
+		return local, nil
+	}
+	return mergeIndex(remote, local), nil
+}
+
+// mergeIndex 合并索引, 同类型插件以 primary 为准
+func mergeIndex(primary, secondary *Index) *Index {
+	exist := map[string]bool{}
+	for _, p := range primary.Plugins {
+		exist[p.Type] = true
+	}
+	for _, p := range secondary.Plugins {
+		if !exist[p.Type] {
+			primary.Plugins = append(primary.Plugins, p)
+		}
+	}
+	return primary
+}
+
+// GetIndexURL 获取插件索引地址
+func GetIndexURL() string {
+	cfg, _ := config.LoadConfig()
+	if cfg != nil && len(cfg.Plugin.Index) > 0 {
+		return cfg.Plugin.Index
+	}
+	return common.DefaultPluginIndexURL
+}
+
+// UpdateIndex 拉取远程插件索引并缓存到本地
+func UpdateIndex(url string) (*Index, error) {
+	log := log.GetInstance()
+	if len(url) == 0 {
+		url = GetIndexURL()
+	}
+	log.Debugf("fetch plugin index from %s", url)
+	client := req.C().SetLogger(nil).SetUserAgent(common.GetUG()).SetTimeout(time.Second * 30)
+	resp, err := client.R().Get(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetch plugin index %s failed", url)
+	}
+	if !resp.IsSuccessState() {
+		return nil, errors.Errorf("fetch plugin index %s failed, bad response status %v", url, resp.Status)
+	}
+	idx, err := parseIndex(resp.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse plugin index %s failed", url)
+	}
+	idx.APIVersion = indexAPIVersion
+	idx.Source = url
+	if idx.Generated.IsZero() {
+		idx.Generated = time.Now()
+	}
+	content, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := file.WriteFile(common.GetPluginIndexCache(), string(content), true); err != nil {
+		return nil, errors.Wrap(err, "cache plugin index failed")
+	}
+	return idx, nil
+}
+
+// InstalledVersion 获取已安装插件的名称与版本
+func InstalledVersion(ctx context.Context, client *k8s.Client, t string) (name, version string, installed bool) {
+	if client == nil {
+		return "", "", false
+	}
+	s, err := client.GetSecret(ctx, common.GetDefaultSystemNamespace(true), common.PluginSecretPrefix+t, metav1.GetOptions{})
+	if err != nil {
+		return "", "", false
+	}
+	if len(s.Data[keyVersion]) == 0 {
+		return "", "", false
+	}
+	return string(s.Data[keyName]), string(s.Data[keyVersion]), true
+}
+
+// checkCompatibility 检查插件对 q 及 k8s 版本的要求
+func (p *Item) checkCompatibility() error {
+	if len(p.MinCLIVersion) > 0 && len(common.Version) > 0 {
+		if ok, err := versionAtLeast(common.Version, p.MinCLIVersion); err == nil && !ok {
+			return errors.Errorf("plugin %s requires q >= %s, current: %s", p.Name, p.MinCLIVersion, common.Version)
+		}
+	}
+	if len(p.MinKubeVersion) > 0 && p.Client != nil {
+		kv, err := p.Client.GetGitVersion(context.TODO())
+		if err != nil {
+			p.log.Debugf("get k8s version failed, skip check: %v", err)
+			return nil
+		}
+		if ok, err := versionAtLeast(kv, p.MinKubeVersion); err == nil && !ok {
+			return errors.Errorf("plugin %s requires k8s >= %s, current: %s", p.Name, p.MinKubeVersion, kv)
+		}
+	}
+	return nil
+}
+
+// verifyChecksum 校验插件文件, kubectl 插件为本地清单, helm 插件为拉取的 chart 包, 格式: sha256:<hex>
+func (p *Item) verifyChecksum(path string) error {
+	if len(p.Checksum) == 0 {
+		return nil
+	}
+	algo, sum, found := strings.Cut(p.Checksum, ":")
+	if !found || algo != "sha256" {
+		return errors.Errorf("plugin %s unsupported checksum %s", p.Name, p.Checksum)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	h := sha256.Sum256(content)
+	if hex.EncodeToString(h[:]) != strings.ToLower(sum) {
+		return errors.Errorf("plugin %s checksum mismatch", p.Name)
+	}
+	return nil
+}
+
+func versionAtLeast(current, min string) (bool, error) {
+	cv, err := gv.NewVersion(current)
+	if err != nil {
+		return false, err
+	}
+	mv, err := gv.NewVersion(min)
+	if err != nil {
+		return false, err
+	}
+	// 忽略预发布及构建信息, 如 v1.24.15+k3s1
+	c, _ := cv.SetPrerelease("")
+	return !c.LessThan(mv), nil
+}
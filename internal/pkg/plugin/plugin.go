@@ -8,35 +8,28 @@ package plugin
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
 	gv "github.com/Masterminds/semver/v3"
+	"github.com/cockroachdb/errors"
 	"github.com/easysoft/qcadmin/common"
 	qcexec "github.com/easysoft/qcadmin/internal/pkg/util/exec"
+	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
 	"github.com/easysoft/qcadmin/internal/pkg/util/log"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	"helm.sh/helm/v3/pkg/release"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
 )
 
 func GetAll() ([]Meta, error) {
-	log := log.GetInstance()
-	var plugins []Meta
-	pf := fmt.Sprintf("%s/hack/manifests/plugins/plugins.json", common.GetDefaultDataDir())
-	log.Debug("load local plugin config from", pf)
-	content, err := os.ReadFile(pf)
-	if err != nil {
-		return nil, err
-	}
-	err = json.Unmarshal(content, &plugins)
+	idx, err := LoadIndex()
 	if err != nil {
-		log.Errorf("unmarshal plugin meta failed: %v", err)
 		return nil, err
 	}
-	return plugins, nil
+	return idx.Plugins, nil
 }
 
 func GetMaps() (map[string]Meta, error) {
@@ -99,140 +92,310 @@ func (p *Item) UnInstall() error {
 		return nil
 	}
 	pluginName := fmt.Sprintf("qc-plugin-%s", p.Type)
-	_, err := p.Client.GetSecret(context.TODO(), common.GetDefaultSystemNamespace(true), pluginName, metav1.GetOptions{})
+	oldSecret, err := p.Client.GetSecret(context.TODO(), common.GetDefaultSystemNamespace(true), pluginName, metav1.GetOptions{})
 	if err != nil {
-		if errors.IsNotFound(err) {
+		if kerrors.IsNotFound(err) {
 			p.log.Warnf("plugin %s is already uninstalled", p.Type)
 			return nil
 		}
 		p.log.Fatalf("get plugin secret failed: %v", err)
 		return nil
 	}
-	// #nosec
+	if len(oldSecret.Data[keyVersion]) == 0 {
+		// 仅记录了失败的安装操作
+		p.log.Warnf("plugin %s is not installed, clean state", p.Type)
+		return p.Client.DeleteSecret(context.TODO(), common.GetDefaultSystemNamespace(true), pluginName, metav1.DeleteOptions{})
+	}
 	if p.Tool == "helm" {
-		applycmd := qcexec.Command(os.Args[0], "experimental", "helm", "delete", p.Type, "-n", common.GetDefaultSystemNamespace(true))
-		if output, err := applycmd.CombinedOutput(); err != nil {
-			p.log.Errorf("helm uninstall %s plugin %s failed: %s", p.Type, p.Name, string(output))
-			return err
-		}
+		err = p.helmUninstall()
 	} else {
 		// #nosec
 		applycmd := qcexec.Command(os.Args[0], "experimental", "kubectl", "delete", "-f", fmt.Sprintf("%s/%s", common.GetDefaultDataDir(), p.Path), "-n", common.GetDefaultSystemNamespace(true), "--kubeconfig", common.GetKubeConfig())
-		if output, err := applycmd.CombinedOutput(); err != nil {
+		if output, cerr := applycmd.CombinedOutput(); cerr != nil {
 			p.log.Errorf("kubectl uninstall %s plugin %s failed: %s", p.Type, p.Name, string(output))
-			return err
+			err = errors.Errorf("uninstall %s plugin %s failed: %v", p.Type, p.Name, cerr)
 		}
 	}
+	if err != nil {
+		if serr := p.saveState(OpUninstall, "", nil, err); serr != nil {
+			p.log.Warnf("save plugin %s state failed: %v", p.Type, serr)
+		}
+		return err
+	}
 	p.log.Donef("uninstall %s plugin success.", p.Type)
 	p.Client.DeleteSecret(context.TODO(), common.GetDefaultSystemNamespace(true), pluginName, metav1.DeleteOptions{})
 	return nil
 }
 
+func (p *Item) helmClient() (*helm.Client, error) {
+	return helm.NewClient(&helm.Config{Namespace: common.GetDefaultSystemNamespace(true), Atomic: true, Progress: p.log.Debugf})
+}
+
+func (p *Item) helmUninstall() error {
+	hc, err := p.helmClient()
+	if err != nil {
+		return errors.Wrap(err, "create helm client failed")
+	}
+	if rel, _ := hc.GetDetail(p.Type); rel == nil {
+		p.log.Debugf("release %s not found, skip", p.Type)
+		return nil
+	}
+	_, err = hc.Uninstall(p.Type)
+	return err
+}
+
 func (p *Item) Install() error {
+	d := &depInstaller{
+		resolve: GetMeta,
+		installed: func(t string) bool {
+			_, _, ok := InstalledVersion(context.TODO(), p.Client, t)
+			return ok
+		},
+		install: func(item *Item) error {
+			item.Client = p.Client
+			return item.install()
+		},
+		installing: map[string]bool{},
+		done:       map[string]bool{},
+	}
+	return d.run(p)
+}
+
+// depInstaller 按依赖顺序安装插件, installing 为当前安装链路, 用于检测循环依赖, done 为已处理的插件
+type depInstaller struct {
+	resolve    func(args ...string) (Item, error)
+	installed  func(t string) bool
+	install    func(item *Item) error
+	installing map[string]bool
+	done       map[string]bool
+}
+
+// run 先按声明顺序安装依赖, 已安装或已处理的依赖跳过, 再安装插件本身
+func (d *depInstaller) run(p *Item) error {
+	d.installing[p.Type] = true
+	defer delete(d.installing, p.Type)
+	for _, dep := range p.Dependencies {
+		item, err := d.resolve(dep)
+		if err != nil {
+			return errors.Errorf("plugin %s depends on %s: %v", p.Type, dep, err)
+		}
+		if d.done[item.Type] {
+			continue
+		}
+		if d.installing[item.Type] {
+			return errors.Errorf("plugin %s has circular dependency on %s", p.Type, item.Type)
+		}
+		if d.installed(item.Type) {
+			p.log.Debugf("dependency %s of plugin %s already installed", item.Type, p.Type)
+			d.done[item.Type] = true
+			continue
+		}
+		p.log.Infof("plugin %s depends on %s, install it first", p.Type, item.Type)
+		if err := d.run(&item); err != nil {
+			return err
+		}
+	}
+	if err := d.install(p); err != nil {
+		return err
+	}
+	d.done[p.Type] = true
+	return nil
+}
+
+// targetVersion 指定版本优先, 否则使用索引中的版本
+func (p *Item) targetVersion() string {
+	if len(p.InstallVersion) > 0 {
+		return p.InstallVersion
+	}
+	return p.Version
+}
+
+// newerThan 非 semver 版本号仅比较是否一致
+func newerThan(now, old string) bool {
+	nv, err := gv.NewVersion(strings.TrimPrefix(now, "v"))
+	if err != nil {
+		return now != old
+	}
+	ov, err := gv.NewVersion(strings.TrimPrefix(old, "v"))
+	if err != nil {
+		return now != old
+	}
+	return nv.GreaterThan(ov)
+}
+
+// install 安装单个插件, 依赖由 depInstaller 提前安装
+func (p *Item) install() error {
+	if err := p.checkCompatibility(); err != nil {
+		return err
+	}
+	if p.Tool != "helm" {
+		if err := p.verifyChecksum(fmt.Sprintf("%s/%s", common.GetDefaultDataDir(), p.Path)); err != nil {
+			return err
+		}
+	}
 	pluginName := fmt.Sprintf("qc-plugin-%s", p.Type)
 	oldSecret, err := p.Client.GetSecret(context.TODO(), common.GetDefaultSystemNamespace(true), pluginName, metav1.GetOptions{})
-	updatestatus := false
-	if err == nil {
-		nowversion := gv.MustParse(strings.TrimPrefix(p.Version, "v"))
-		oldversion := string(oldSecret.Data["version"])
+	if err == nil && len(oldSecret.Data[keyVersion]) > 0 {
+		nowversion := p.targetVersion()
+		oldversion := string(oldSecret.Data[keyVersion])
 		p.log.Debugf("type: %s, old version: %s, now version: %s", p.Type, oldversion, nowversion)
-		needupgrade := nowversion.GreaterThan(gv.MustParse(oldversion))
-		if !needupgrade {
+		if !newerThan(nowversion, oldversion) && len(p.Values) == 0 {
 			p.log.Warnf("plugin %s is the latest version", p.Type)
 			return nil
 		}
-		updatestatus = true
-	} else {
-		if !errors.IsNotFound(err) {
-			p.log.Debugf("get plugin secret failed: %v", err)
-			return fmt.Errorf("plugin %s install failed", p.Name)
-		}
+	} else if err != nil && !kerrors.IsNotFound(err) {
+		p.log.Debugf("get plugin secret failed: %v", err)
+		return fmt.Errorf("plugin %s install failed", p.Name)
 	}
+	values, err := p.mergedValues(oldSecret)
+	if err != nil {
+		return err
+	}
+	version, err := p.apply(OpInstall, values)
+	if err != nil {
+		return err
+	}
+	p.log.Donef("upgrade install %s plugin %s success.", p.Type, p.Name)
+	return p.saveState(OpInstall, version, values, nil)
+}
+
+// apply 安装或升级插件, helm 插件失败时自动回滚, 返回实际安装的版本
+func (p *Item) apply(op string, values map[string]interface{}) (string, error) {
+	version := p.targetVersion()
+	var err error
 	if p.Tool == "helm" {
-		args := []string{"experimental", "helm", "upgrade", "--name", p.Type, "--repo", common.DefaultHelmRepoName, "--chart", p.Path, "--namespace", common.GetDefaultSystemNamespace(true)}
-		if len(p.InstallVersion) > 0 {
-			args = append(args, "--version", p.InstallVersion)
+		var hc *helm.Client
+		hc, err = p.helmClient()
+		if err != nil {
+			return "", errors.Wrap(err, "create helm client failed")
 		}
-		applycmd := qcexec.Command(os.Args[0], args...)
-		if output, err := applycmd.CombinedOutput(); err != nil {
-			p.log.Errorf("helm install %s plugin %s failed: %s", p.Type, p.Name, string(output))
-			return err
+		rel, uerr := p.helmUpgrade(hc, values)
+		if uerr != nil {
+			p.log.Errorf("helm %s %s plugin %s failed: %v", op, p.Type, p.Name, uerr)
+			err = errors.Errorf("%s %s plugin %s failed: %v", op, p.Type, p.Name, uerr)
+		} else if rel != nil && rel.Chart != nil && rel.Chart.Metadata != nil {
+			version = rel.Chart.Metadata.Version
 		}
 	} else {
 		// #nosec
 		applycmd := qcexec.Command(os.Args[0], "experimental", "kubectl", "apply", "-f", fmt.Sprintf("%s/%s", common.GetDefaultDataDir(), p.Path), "-n", common.GetDefaultSystemNamespace(true), "--kubeconfig", common.GetKubeConfig())
-		if output, err := applycmd.CombinedOutput(); err != nil {
-			p.log.Errorf("kubectl install %s plugin %s failed: %s", p.Type, p.Name, string(output))
-			return err
+		if output, cerr := applycmd.CombinedOutput(); cerr != nil {
+			p.log.Errorf("kubectl %s %s plugin %s failed: %s", op, p.Type, p.Name, string(output))
+			err = errors.Errorf("%s %s plugin %s failed: %v", op, p.Type, p.Name, cerr)
 		}
 	}
-
-	p.log.Donef("upgrade install %s plugin %s success.", p.Type, p.Name)
-	plugindata := map[string]string{
-		"type":       p.Type,
-		"name":       p.Name,
-		"version":    p.Version,
-		"cliversion": common.Version,
-	}
-	if updatestatus {
-		_, err = p.Client.UpdateSecret(context.TODO(), common.GetDefaultSystemNamespace(true), &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: pluginName,
-			},
-			StringData: plugindata,
-		}, metav1.UpdateOptions{})
-	} else {
-		_, err = p.Client.CreateSecret(context.TODO(), common.GetDefaultSystemNamespace(true), &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: pluginName,
-			},
-			StringData: plugindata,
-		}, metav1.CreateOptions{})
+	if err != nil {
+		if serr := p.saveState(op, version, values, err); serr != nil {
+			p.log.Warnf("save plugin %s state failed: %v", p.Type, serr)
+		}
+		return "", err
 	}
+	return version, nil
+}
 
-	return err
+// helmUpgrade 索引提供校验值时先拉取 chart 包校验, 校验通过后以该 chart 包安装
+func (p *Item) helmUpgrade(hc *helm.Client, values map[string]interface{}) (*release.Release, error) {
+	if len(p.Checksum) == 0 {
+		return hc.Upgrade(p.Type, common.DefaultHelmRepoName, p.Path, p.targetVersion(), values)
+	}
+	if len(p.InstallVersion) > 0 && p.InstallVersion != p.Version {
+		return nil, errors.Errorf("plugin %s checksum is for version %s, cannot verify version %s", p.Name, p.Version, p.InstallVersion)
+	}
+	dir, err := os.MkdirTemp("", "qc-plugin-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	chartPath, err := hc.Pull(common.DefaultHelmRepoName, p.Path, p.Version, dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.verifyChecksum(chartPath); err != nil {
+		return nil, err
+	}
+	return hc.UpgradeChart(p.Type, chartPath, values)
 }
 
 func (p *Item) Upgrade() (err error) {
 	pluginName := fmt.Sprintf("qc-plugin-%s", p.Type)
 	oldSecret, _ := p.Client.GetSecret(context.TODO(), common.GetDefaultSystemNamespace(true), pluginName, metav1.GetOptions{})
-	updatestatus := true
-	if oldSecret == nil {
-		updatestatus = false
+	values, err := p.mergedValues(oldSecret)
+	if err != nil {
+		return err
 	}
+	version, err := p.apply(OpUpgrade, values)
+	if err != nil {
+		return err
+	}
+	p.log.Donef("upgrade %s plugin %s to %s success.", p.Type, p.Name, version)
+	return p.saveState(OpUpgrade, version, values, nil)
+}
 
-	if p.Tool == "helm" {
-		applycmd := qcexec.Command(os.Args[0], "experimental", "helm", "upgrade", "--name", p.Type, "--repo", common.DefaultHelmRepoName, "--chart", p.Path, "--namespace", common.GetDefaultSystemNamespace(true))
-		if output, err := applycmd.CombinedOutput(); err != nil {
-			p.log.Errorf("helm upgrade %s plugin %s failed: %s", p.Type, p.Name, string(output))
-			return err
-		}
-	} else {
-		// #nosec
-		applycmd := qcexec.Command(os.Args[0], "experimental", "kubectl", "apply", "-f", fmt.Sprintf("%s/%s", common.GetDefaultDataDir(), p.Path), "-n", common.GetDefaultSystemNamespace(true), "--kubeconfig", common.GetKubeConfig())
-		if output, err := applycmd.CombinedOutput(); err != nil {
-			p.log.Errorf("kubectl upgrade %s plugin %s failed: %s", p.Type, p.Name, string(output))
-			return err
+// Rollback 回滚到上一次成功安装的版本及 values, 仅支持 helm 插件
+func (p *Item) Rollback() error {
+	if p.Tool != "helm" {
+		return errors.Errorf("plugin %s is installed by %s, rollback is only supported for helm plugins", p.Type, p.Tool)
+	}
+	s, err := p.Client.GetSecret(context.TODO(), common.GetDefaultSystemNamespace(true), common.PluginSecretPrefix+p.Type, metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return errors.Errorf("plugin %s is not installed", p.Type)
 		}
+		return err
 	}
-	p.log.Donef("upgrade %s plugin %s success.", p.Type, p.Name)
-	plugindata := map[string]string{
-		"type":       p.Type,
-		"name":       p.Name,
-		"version":    p.Version,
-		"cliversion": common.Version,
+	prevVersion := string(s.Data[keyPrevVersion])
+	if len(prevVersion) == 0 {
+		return errors.Errorf("plugin %s has no previous version to rollback", p.Type)
 	}
-	if updatestatus {
-		oldSecret.StringData = plugindata
-		_, err = p.Client.UpdateSecret(context.TODO(), common.GetDefaultSystemNamespace(true), oldSecret, metav1.UpdateOptions{})
-	} else {
-		_, err = p.Client.CreateSecret(context.TODO(), common.GetDefaultSystemNamespace(true), &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: pluginName,
-			},
-			StringData: plugindata,
-		}, metav1.CreateOptions{})
+	prevValues := map[string]interface{}{}
+	if err := yaml.Unmarshal(s.Data[keyPrevValues], &prevValues); err != nil {
+		return errors.Wrap(err, "parse previous plugin values failed")
+	}
+	hc, err := p.helmClient()
+	if err != nil {
+		return errors.Wrap(err, "create helm client failed")
+	}
+	revision, err := previousRevision(hc, p.Type, prevVersion)
+	if err != nil {
+		return err
 	}
+	p.log.Infof("rollback %s plugin to %s (revision %d)", p.Type, prevVersion, revision)
+	if err := hc.Rollback(p.Type, revision); err != nil {
+		if serr := p.saveState(OpRollback, prevVersion, prevValues, err); serr != nil {
+			p.log.Warnf("save plugin %s state failed: %v", p.Type, serr)
+		}
+		return err
+	}
+	if name := string(s.Data[keyPrevName]); len(name) > 0 {
+		p.Name = name
+	}
+	p.log.Donef("rollback %s plugin to %s success.", p.Type, prevVersion)
+	return p.saveState(OpRollback, prevVersion, prevValues, nil)
+}
 
-	return err
+// previousRevision 查找上一个成功部署的版本, 跳过失败及自动回滚产生的版本
+func previousRevision(hc *helm.Client, name, version string) (int, error) {
+	history, err := hc.History(name, 0)
+	if err != nil {
+		return 0, err
+	}
+	if len(history) < 2 {
+		return 0, errors.Errorf("release %s has no previous revision", name)
+	}
+	fallback := 0
+	for _, r := range history[1:] {
+		if r.Info == nil || r.Info.Status != release.StatusSuperseded {
+			continue
+		}
+		if r.Chart != nil && r.Chart.Metadata != nil && r.Chart.Metadata.Version == version {
+			return r.Version, nil
+		}
+		if fallback == 0 {
+			fallback = r.Version
+		}
+	}
+	if fallback == 0 {
+		return 0, errors.Errorf("release %s has no previous revision", name)
+	}
+	return fallback, nil
 }
@@ -0,0 +1,215 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
+	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/helmpath"
+	"helm.sh/helm/v3/pkg/repo"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestMain 家目录在进程内会被缓存, 测试共用临时家目录
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "qcadmin-plugin")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", home)
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+func newTestInstaller(graph map[string][]string, installed ...string) (*depInstaller, *[]string) {
+	var order []string
+	exist := map[string]bool{}
+	for _, t := range installed {
+		exist[t] = true
+	}
+	return &depInstaller{
+		resolve: func(args ...string) (Item, error) {
+			deps, ok := graph[args[0]]
+			if !ok {
+				return Item{}, fmt.Errorf("plugin %s not found", args[0])
+			}
+			return Item{Type: args[0], Dependencies: deps, log: log.GetInstance()}, nil
+		},
+		installed: func(t string) bool { return exist[t] },
+		install: func(item *Item) error {
+			order = append(order, item.Type)
+			return nil
+		},
+		installing: map[string]bool{},
+		done:       map[string]bool{},
+	}, &order
+}
+
+func TestDepInstaller(t *testing.T) {
+	tests := []struct {
+		name      string
+		graph     map[string][]string
+		installed []string
+		want      []string
+		wantErr   string
+	}{
+		{
+			name:  "chain",
+			graph: map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil},
+			want:  []string{"c", "b", "a"},
+		},
+		{
+			name:  "diamond",
+			graph: map[string][]string{"a": {"b", "c"}, "b": {"d"}, "c": {"d"}, "d": nil},
+			want:  []string{"d", "b", "c", "a"},
+		},
+		{
+			name:      "installed dependency skipped",
+			graph:     map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil},
+			installed: []string{"b"},
+			want:      []string{"a"},
+		},
+		{
+			name:    "circular",
+			graph:   map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}},
+			wantErr: "circular dependency on a",
+		},
+		{
+			name:    "missing",
+			graph:   map[string][]string{"a": {"x"}},
+			wantErr: "plugin a depends on x",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, order := newTestInstaller(tt.graph, tt.installed...)
+			root, _ := d.resolve("a")
+			err := d.run(&root)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*order, tt.want) {
+				t.Fatalf("install order = %v, want %v", *order, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateValues(t *testing.T) {
+	p := &Item{Type: "demo", ValuesSchema: []byte(`{"type":"object","properties":{"replicas":{"type":"integer"}}}`)}
+	if err := p.validateValues(map[string]interface{}{"replicas": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.validateValues(map[string]interface{}{"replicas": "two"}); err == nil {
+		t.Fatal("expect schema validation error")
+	}
+}
+
+// newTestChartRepo 本地 chart 仓库, 返回 chart 包的 sha256
+func newTestChartRepo(t *testing.T) string {
+	dir := t.TempDir()
+	path, err := chartutil.Save(&chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "demo", Version: "0.1.0"}}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(srv.Close)
+	idx := repo.NewIndexFile()
+	h := sha256.Sum256(content)
+	if err := idx.MustAdd(&chart.Metadata{APIVersion: chart.APIVersionV2, Name: "demo", Version: "0.1.0"}, filepath.Base(path), srv.URL, "sha256:"+hex.EncodeToString(h[:])); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.WriteFile(filepath.Join(dir, "index.yaml"), common.FileMode0644); err != nil {
+		t.Fatal(err)
+	}
+	repoFile := filepath.Join(t.TempDir(), "repositories.yaml")
+	f := repo.NewFile()
+	f.Update(&repo.Entry{Name: common.DefaultHelmRepoName, URL: srv.URL})
+	if err := f.WriteFile(repoFile, common.FileMode0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HELM_REPOSITORY_CONFIG", repoFile)
+	if err := os.MkdirAll(common.GetDefaultCacheDir(), common.FileMode0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.WriteFile(filepath.Join(common.GetDefaultCacheDir(), helmpath.CacheIndexFile(common.DefaultHelmRepoName)), common.FileMode0644); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(h[:])
+}
+
+func TestInstallChecksumMismatch(t *testing.T) {
+	sum := newTestChartRepo(t)
+	manifest := filepath.Join(common.GetDefaultDataDir(), "demo.yaml")
+	if err := os.MkdirAll(filepath.Dir(manifest), common.FileMode0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manifest, []byte("kind: ConfigMap\n"), common.FileMode0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		item Item
+	}{
+		{"helm chart", Item{Name: "demo", Type: "demo", Path: "demo", Version: "0.1.0", Tool: "helm", Checksum: "sha256:" + strings.Repeat("0", len(sum))}},
+		{"helm version not verifiable", Item{Name: "demo", Type: "demo", Path: "demo", Version: "0.1.0", Tool: "helm", Checksum: "sha256:" + sum, InstallVersion: "0.2.0"}},
+		{"kubectl manifest", Item{Name: "demo", Type: "demo", Path: "demo.yaml", Version: "0.1.0", Tool: "kubectl", Checksum: "sha256:" + sum}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.item
+			p.Client = &k8s.Client{Clientset: fake.NewSimpleClientset()}
+			p.log = log.GetInstance()
+			err := p.Install()
+			if err == nil || !strings.Contains(err.Error(), "checksum") {
+				t.Fatalf("Install() error = %v, want checksum error", err)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksumChart(t *testing.T) {
+	sum := newTestChartRepo(t)
+	hc, err := helm.NewClient(&helm.Config{Namespace: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := hc.Pull(common.DefaultHelmRepoName, "demo", "0.1.0", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Item{Name: "demo", Checksum: "sha256:" + sum}
+	if err := p.verifyChecksum(path); err != nil {
+		t.Fatal(err)
+	}
+}
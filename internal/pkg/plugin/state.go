@@ -0,0 +1,221 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+	HealthUnknown   = "unknown"
+	HealthDisabled  = "disabled"
+
+	ResultSuccess = "success"
+	ResultFailed  = "failed"
+
+	OpInstall   = "install"
+	OpUpgrade   = "upgrade"
+	OpUninstall = "uninstall"
+	OpRollback  = "rollback"
+)
+
+// secret keys
+const (
+	keyType          = "type"
+	keyName          = "name"
+	keyVersion       = "version"
+	keyCLIVersion    = "cliversion"
+	keyInstallTime   = "installtime"
+	keyUpdateTime    = "updatetime"
+	keyValuesHash    = "valueshash"
+	keyLastOperation = "operation"
+	keyLastResult    = "result"
+	keyLastError     = "error"
+	keyPrevName      = "prevname"
+	keyPrevVersion   = "prevversion"
+	keyPrevValues    = "prevvalues"
+)
+
+// releaseSelector helm 插件的 release 名称与插件类型一致
+func releaseSelector(t string) string {
+	return fmt.Sprintf("app.kubernetes.io/instance=%s", t)
+}
+
+func valuesHash(values string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	h := sha256.Sum256([]byte(values))
+	return hex.EncodeToString(h[:])[:12]
+}
+
+// saveState 记录插件操作结果, 成功时将当前版本及 values 记为上一版本, 失败时保留原有信息
+func (p *Item) saveState(op, version string, values map[string]interface{}, opErr error) error {
+	ns := common.GetDefaultSystemNamespace(true)
+	secretName := common.PluginSecretPrefix + p.Type
+	old, err := p.Client.GetSecret(context.TODO(), ns, secretName, metav1.GetOptions{})
+	exist := err == nil
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+	data := map[string]string{}
+	if exist {
+		for k, v := range old.Data {
+			data[k] = string(v)
+		}
+	}
+	prevVersion := data[keyVersion]
+	now := time.Now().Format(time.RFC3339)
+	data[keyLastOperation] = op
+	data[keyUpdateTime] = now
+	if opErr != nil {
+		data[keyLastResult] = ResultFailed
+		data[keyLastError] = opErr.Error()
+	} else {
+		content := marshalValues(values)
+		if len(prevVersion) > 0 {
+			data[keyPrevName] = data[keyName]
+			data[keyPrevVersion] = prevVersion
+			data[keyPrevValues] = data[valuesKey]
+		}
+		data[keyLastResult] = ResultSuccess
+		data[keyLastError] = ""
+		data[keyType] = p.Type
+		data[keyName] = p.Name
+		data[keyVersion] = version
+		data[keyCLIVersion] = common.Version
+		data[valuesKey] = content
+		data[keyValuesHash] = valuesHash(content)
+		if len(prevVersion) == 0 {
+			data[keyInstallTime] = now
+		}
+	}
+	if exist {
+		old.Data = nil
+		old.StringData = data
+		_, err = p.Client.UpdateSecret(context.TODO(), ns, old, metav1.UpdateOptions{})
+		return err
+	}
+	_, err = p.Client.CreateSecret(context.TODO(), ns, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: secretName,
+		},
+		StringData: data,
+	}, metav1.CreateOptions{})
+	return err
+}
+
+// GetState 获取插件状态, 包括所属工作负载的就绪情况
+func GetState(ctx context.Context, client *k8s.Client, t string) (*State, error) {
+	state := &State{Type: t, Health: HealthDisabled}
+	ns := common.GetDefaultSystemNamespace(true)
+	s, err := client.GetSecret(ctx, ns, common.PluginSecretPrefix+t, metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+	state.Name = string(s.Data[keyName])
+	state.Version = string(s.Data[keyVersion])
+	state.CLIVersion = string(s.Data[keyCLIVersion])
+	state.InstallTime = string(s.Data[keyInstallTime])
+	state.UpdateTime = string(s.Data[keyUpdateTime])
+	state.ValuesHash = string(s.Data[keyValuesHash])
+	state.PrevVersion = string(s.Data[keyPrevVersion])
+	state.LastOperation = string(s.Data[keyLastOperation])
+	state.LastResult = string(s.Data[keyLastResult])
+	state.LastError = string(s.Data[keyLastError])
+	// 旧版本仅记录了版本信息
+	if len(state.LastResult) == 0 && len(state.Version) > 0 {
+		state.LastResult = ResultSuccess
+	}
+	state.Installed = len(state.Version) > 0
+	if !state.Installed {
+		return state, nil
+	}
+	state.Workloads, err = listWorkloads(ctx, client, ns, t)
+	if err != nil {
+		state.Health = HealthUnknown
+		return state, err
+	}
+	state.Health = HealthUnknown
+	if len(state.Workloads) > 0 {
+		state.Health = HealthHealthy
+		for _, w := range state.Workloads {
+			if w.Ready < w.Desired || w.Desired == 0 {
+				state.Health = HealthUnhealthy
+				break
+			}
+		}
+	}
+	return state, nil
+}
+
+func listWorkloads(ctx context.Context, client *k8s.Client, ns, t string) ([]Workload, error) {
+	opts := metav1.ListOptions{LabelSelector: releaseSelector(t)}
+	var workloads []Workload
+	deploys, err := client.ListDeployments(ctx, ns, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deploys.Items {
+		desired := 1
+		if d.Spec.Replicas != nil {
+			desired = int(*d.Spec.Replicas)
+		}
+		workloads = append(workloads, Workload{Kind: "Deployment", Name: d.Name, Desired: desired, Ready: int(d.Status.ReadyReplicas)})
+	}
+	sts, err := client.ListStatefulSets(ctx, ns, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sts.Items {
+		desired := 1
+		if s.Spec.Replicas != nil {
+			desired = int(*s.Spec.Replicas)
+		}
+		workloads = append(workloads, Workload{Kind: "StatefulSet", Name: s.Name, Desired: desired, Ready: int(s.Status.ReadyReplicas)})
+	}
+	ds, err := client.ListDaemonSet(ctx, ns, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range ds.Items {
+		workloads = append(workloads, Workload{Kind: "DaemonSet", Name: d.Name, Desired: int(d.Status.DesiredNumberScheduled), Ready: int(d.Status.NumberReady)})
+	}
+	return workloads, nil
+}
+
+// GetStates 获取索引中所有插件的状态
+func GetStates(ctx context.Context, client *k8s.Client) ([]State, error) {
+	plugins, err := GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var states []State
+	for _, p := range plugins {
+		s, err := GetState(ctx, client, p.Type)
+		if s == nil {
+			return nil, err
+		}
+		states = append(states, *s)
+	}
+	return states, nil
+}
@@ -7,11 +7,22 @@
 package plugin
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/easysoft/qcadmin/internal/pkg/util/log"
 
 	"github.com/easysoft/qcadmin/internal/pkg/k8s"
 )
 
+// Index plugin index, local staged or fetched from remote
+type Index struct {
+	APIVersion string    `json:"apiVersion"`
+	Generated  time.Time `json:"generated,omitempty"`
+	Source     string    `json:"source,omitempty"`
+	Plugins    []Meta    `json:"plugins"`
+}
+
 type Meta struct {
 	Type    string `json:"type"`
 	Default string `json:"default"`
@@ -19,18 +30,50 @@ type Meta struct {
 }
 
 type Item struct {
-	Client         *k8s.Client `json:"-"`
-	Name           string      `json:"name"`
-	Description    string      `json:"description"`
-	Version        string      `json:"version"`
-	Home           string      `json:"home"`
-	Appversion     string      `json:"appversion"`
-	Type           string      `json:"type"`
-	Path           string      `json:"path"`
-	Tool           string      `json:"tool"`
-	BuiltIn        bool        `json:"builtin"`
-	InstallVersion string      `json:"-"`
-	log            log.Logger  `json:"-"`
+	Client         *k8s.Client            `json:"-"`
+	Name           string                 `json:"name"`
+	Description    string                 `json:"description"`
+	Version        string                 `json:"version"`
+	Home           string                 `json:"home"`
+	Appversion     string                 `json:"appversion"`
+	Type           string                 `json:"type"`
+	Path           string                 `json:"path"`
+	Tool           string                 `json:"tool"`
+	BuiltIn        bool                   `json:"builtin"`
+	Dependencies   []string               `json:"dependencies,omitempty"`
+	MinCLIVersion  string                 `json:"min_cli_version,omitempty"`
+	MinKubeVersion string                 `json:"min_kube_version,omitempty"`
+	ValuesSchema   json.RawMessage        `json:"values_schema,omitempty"`
+	Checksum       string                 `json:"checksum,omitempty"`
+	InstallVersion string                 `json:"-"`
+	Values         map[string]interface{} `json:"-"`
+	log            log.Logger             `json:"-"`
 }
 
 type List []Meta
+
+// State plugin installed state, persisted in qc-plugin-<type> secret
+type State struct {
+	Type          string     `json:"type" yaml:"type"`
+	Name          string     `json:"name,omitempty" yaml:"name,omitempty"`
+	Version       string     `json:"version,omitempty" yaml:"version,omitempty"`
+	CLIVersion    string     `json:"cliVersion,omitempty" yaml:"cliVersion,omitempty"`
+	Installed     bool       `json:"installed" yaml:"installed"`
+	Health        string     `json:"health" yaml:"health"`
+	InstallTime   string     `json:"installTime,omitempty" yaml:"installTime,omitempty"`
+	UpdateTime    string     `json:"updateTime,omitempty" yaml:"updateTime,omitempty"`
+	ValuesHash    string     `json:"valuesHash,omitempty" yaml:"valuesHash,omitempty"`
+	PrevVersion   string     `json:"previousVersion,omitempty" yaml:"previousVersion,omitempty"`
+	LastOperation string     `json:"lastOperation,omitempty" yaml:"lastOperation,omitempty"`
+	LastResult    string     `json:"lastResult,omitempty" yaml:"lastResult,omitempty"`
+	LastError     string     `json:"lastError,omitempty" yaml:"lastError,omitempty"`
+	Workloads     []Workload `json:"workloads,omitempty" yaml:"workloads,omitempty"`
+}
+
+// Workload workload owned by plugin
+type Workload struct {
+	Kind    string `json:"kind" yaml:"kind"`
+	Name    string `json:"name" yaml:"name"`
+	Desired int    `json:"desired" yaml:"desired"`
+	Ready   int    `json:"ready" yaml:"ready"`
+}
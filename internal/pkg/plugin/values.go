@@ -0,0 +1,104 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const valuesKey = "values"
+
+// secretValues 解析插件 secret 中保存的自定义 values
+func secretValues(s *corev1.Secret) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if s == nil || len(s.Data[valuesKey]) == 0 {
+		return values, nil
+	}
+	if err := yaml.Unmarshal(s.Data[valuesKey], &values); err != nil {
+		return nil, errors.Wrap(err, "parse plugin values failed")
+	}
+	return values, nil
+}
+
+// StoredValues 获取插件安装时保存的自定义 values
+func StoredValues(ctx context.Context, client *k8s.Client, t string) (map[string]interface{}, error) {
+	s, err := client.GetSecret(ctx, common.GetDefaultSystemNamespace(true), common.PluginSecretPrefix+t, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return secretValues(s)
+}
+
+// mergedValues 已保存的 values 与本次指定的 values 合并, 本次指定的优先, 索引声明了 schema 时校验合并结果
+func (p *Item) mergedValues(s *corev1.Secret) (map[string]interface{}, error) {
+	stored, err := secretValues(s)
+	if err != nil {
+		return nil, err
+	}
+	values := helm.MergeMaps(stored, p.Values)
+	if err := p.validateValues(values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// validateValues 按索引中的 values_schema (json schema) 校验自定义 values
+func (p *Item) validateValues(values map[string]interface{}) error {
+	if len(p.ValuesSchema) == 0 {
+		return nil
+	}
+	if err := chartutil.ValidateAgainstSingleSchema(values, p.ValuesSchema); err != nil {
+		return errors.Wrapf(err, "invalid values of plugin %s", p.Type)
+	}
+	return nil
+}
+
+func marshalValues(values map[string]interface{}) string {
+	if len(values) == 0 {
+		return ""
+	}
+	content, _ := yaml.Marshal(values)
+	return string(content)
+}
+
+// Diff 渲染插件清单并与当前已安装的版本对比, 仅支持 helm 插件
+func (p *Item) Diff() ([]helm.ManifestDiff, error) {
+	if p.Tool != "helm" {
+		return nil, errors.Errorf("plugin %s is installed by %s, diff is only supported for helm plugins", p.Type, p.Tool)
+	}
+	s, err := p.Client.GetSecret(context.TODO(), common.GetDefaultSystemNamespace(true), common.PluginSecretPrefix+p.Type, metav1.GetOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, err
+	}
+	values, err := p.mergedValues(s)
+	if err != nil {
+		return nil, err
+	}
+	hc, err := helm.NewClient(&helm.Config{Namespace: common.GetDefaultSystemNamespace(true)})
+	if err != nil {
+		return nil, errors.Wrap(err, "create helm client failed")
+	}
+	current := ""
+	if rel, err := hc.GetDetail(p.Type); err == nil && rel != nil {
+		current = rel.Manifest
+	}
+	rendered, err := hc.DryRunUpgrade(p.Type, common.DefaultHelmRepoName, p.Path, p.targetVersion(), values)
+	if err != nil {
+		return nil, errors.Wrapf(err, "render plugin %s failed", p.Type)
+	}
+	return helm.DiffManifests(current, rendered.Manifest), nil
+}
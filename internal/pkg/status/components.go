@@ -0,0 +1,187 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package status
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	helmReleaseAnnotation = "meta.helm.sh/release-name"
+	instanceLabel         = "app.kubernetes.io/instance"
+	// maxWarnings 每个组件展示的告警事件数
+	maxWarnings   = 3
+	warningWindow = time.Hour
+)
+
+// workload 发现的工作负载
+type workload struct {
+	meta     metav1.ObjectMeta
+	selector *metav1.LabelSelector
+	state    PodStateCount
+}
+
+// releaseOf 工作负载所属的 helm release
+func releaseOf(meta metav1.ObjectMeta) string {
+	if r := meta.Annotations[helmReleaseAnnotation]; len(r) > 0 {
+		return r
+	}
+	return meta.Labels[instanceLabel]
+}
+
+// discoverWorkloads 列出 namespace 下属于 releases 的 Deployment, StatefulSet 及 DaemonSet
+func (k *K8sStatusCollector) discoverWorkloads(ctx context.Context, ns string, releases map[string]bool) ([]workload, error) {
+	var result []workload
+	deploys, err := k.client.ListDeployments(ctx, ns, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deploys.Items {
+		desired := int(d.Status.Replicas)
+		if d.Spec.Replicas != nil {
+			desired = int(*d.Spec.Replicas)
+		}
+		result = append(result, workload{meta: d.ObjectMeta, selector: d.Spec.Selector, state: PodStateCount{
+			Type:        "Deployment",
+			Desired:     desired,
+			Ready:       int(d.Status.ReadyReplicas),
+			Available:   int(d.Status.AvailableReplicas),
+			Unavailable: int(d.Status.UnavailableReplicas),
+		}})
+	}
+	sts, err := k.client.ListStatefulSets(ctx, ns, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sts.Items {
+		desired := int(s.Status.Replicas)
+		if s.Spec.Replicas != nil {
+			desired = int(*s.Spec.Replicas)
+		}
+		result = append(result, workload{meta: s.ObjectMeta, selector: s.Spec.Selector, state: PodStateCount{
+			Type:        "StatefulSet",
+			Desired:     desired,
+			Ready:       int(s.Status.ReadyReplicas),
+			Available:   int(s.Status.AvailableReplicas),
+			Unavailable: desired - int(s.Status.AvailableReplicas),
+		}})
+	}
+	ds, err := k.client.ListDaemonSet(ctx, ns, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range ds.Items {
+		result = append(result, workload{meta: d.ObjectMeta, selector: d.Spec.Selector, state: PodStateCount{
+			Type:        "DaemonSet",
+			Desired:     int(d.Status.DesiredNumberScheduled),
+			Ready:       int(d.Status.NumberReady),
+			Available:   int(d.Status.NumberAvailable),
+			Unavailable: int(d.Status.NumberUnavailable),
+		}})
+	}
+	owned := result[:0]
+	for _, w := range result {
+		if r := releaseOf(w.meta); releases[r] {
+			w.state.Release = r
+			owned = append(owned, w)
+		}
+	}
+	return owned, nil
+}
+
+// componentsStatus 汇总工作负载的副本, 重启次数及最近告警事件
+func (k *K8sStatusCollector) componentsStatus(ctx context.Context, ns string, releases map[string]bool, status *Status) error {
+	workloads, err := k.discoverWorkloads(ctx, ns, releases)
+	if err != nil {
+		return err
+	}
+	pods, err := k.client.ListPods(ctx, ns, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	events, err := k.client.ListEvents(ctx, metav1.ListOptions{FieldSelector: fmt.Sprintf("type=%s,involvedObject.namespace=%s", corev1.EventTypeWarning, ns)})
+	if err != nil {
+		k.option.Log.Debugf("list warning events failed: %v", err)
+		events = &corev1.EventList{}
+	}
+	for _, w := range workloads {
+		objects := map[string]bool{w.meta.Name: true}
+		selector, err := metav1.LabelSelectorAsSelector(w.selector)
+		if err == nil {
+			for _, p := range pods.Items {
+				if !selector.Matches(labels.Set(p.Labels)) {
+					continue
+				}
+				objects[p.Name] = true
+				for _, cs := range p.Status.ContainerStatuses {
+					w.state.Restarts += int(cs.RestartCount)
+				}
+			}
+		}
+		w.state.Warnings = recentWarnings(events.Items, w.meta.Name, objects)
+		status.QStatus.PodState[w.meta.Name] = w.state
+		if n := w.state.Desired - w.state.Ready; n > 0 {
+			k.option.Log.Debugf("%d pods of %s %s are not ready", n, w.state.Type, w.meta.Name)
+		}
+	}
+	return nil
+}
+
+// recentWarnings 工作负载及其 pod, replicaset 最近一小时的告警
+func recentWarnings(events []corev1.Event, name string, objects map[string]bool) []string {
+	var matched []corev1.Event
+	since := time.Now().Add(-warningWindow)
+	for _, e := range events {
+		obj := e.InvolvedObject.Name
+		if !objects[obj] && !(e.InvolvedObject.Kind == "ReplicaSet" && strings.HasPrefix(obj, name+"-")) {
+			continue
+		}
+		if eventTime(e).Before(since) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return eventTime(matched[i]).After(eventTime(matched[j]))
+	})
+	var warnings []string
+	for i, e := range matched {
+		if i >= maxWarnings {
+			break
+		}
+		warnings = append(warnings, fmt.Sprintf("%s: %s", e.Reason, strings.TrimSpace(e.Message)))
+	}
+	return warnings
+}
+
+func eventTime(e corev1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+// hasRelease 是否发现属于 release 的工作负载
+func (q QStatus) hasRelease(release string) bool {
+	for _, state := range q.PodState {
+		if state.Release == release {
+			return true
+		}
+	}
+	return false
+}
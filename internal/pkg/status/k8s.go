@@ -9,13 +9,19 @@ package status
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
 	"github.com/easysoft/qcadmin/internal/pkg/k8s"
 	"github.com/easysoft/qcadmin/internal/pkg/plugin"
+	"github.com/easysoft/qcadmin/internal/pkg/util/kutil"
 	"github.com/easysoft/qcadmin/internal/pkg/util/log"
 	"github.com/ergoapi/util/file"
+	"github.com/imroc/req/v3"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -57,27 +63,55 @@ func (k *K8sStatusCollector) Status(ctx context.Context) (*Status, error) {
 	ctx, cancel := context.WithTimeout(ctx, k.option.waitTimeout())
 	defer cancel()
 
-retry:
-	select {
-	case <-ctx.Done():
-		return mostRecentStatus, fmt.Errorf("timeout while waiting for status to become successful: %w", ctx.Err())
-	default:
-	}
-	s := k.status(ctx)
-	if s != nil {
-		mostRecentStatus = s
-	}
-	if !k.statusIsReady(s) && k.option.Wait {
-		time.Sleep(common.WaitRetryInterval)
-		goto retry
+	for {
+		s := k.status(ctx)
+		if s != nil {
+			mostRecentStatus = s
+		}
+		if k.statusIsReady(s) || !k.option.Wait {
+			return mostRecentStatus, nil
+		}
+		select {
+		case <-ctx.Done():
+			return mostRecentStatus, fmt.Errorf("timeout while waiting for status to become successful, not ready: %s", strings.Join(mostRecentStatus.Failures, "; "))
+		case <-time.After(common.WaitRetryInterval):
+		}
 	}
-
-	return mostRecentStatus, nil
 }
 
+// statusIsReady 检查节点, 组件, 插件及控制台, 未就绪项记录在 Failures
 func (k *K8sStatusCollector) statusIsReady(s *Status) bool {
-	// TODO Check status
-	return true
+	if s == nil {
+		return false
+	}
+	var failures []string
+	total, ready := s.KubeStatus.NodeCount["total"], s.KubeStatus.NodeCount["ready"]
+	if total == 0 || ready < total {
+		failures = append(failures, fmt.Sprintf("nodes: %d/%d ready", ready, total))
+	}
+	for _, states := range []PodStateMap{s.KubeStatus.PodState, s.QStatus.PodState} {
+		for name, state := range states {
+			if state.Disabled {
+				continue
+			}
+			if state.Available < state.Desired {
+				failures = append(failures, fmt.Sprintf("%s %s: %d/%d available", strings.ToLower(state.Type), name, state.Available, state.Desired))
+			}
+		}
+	}
+	if !k.option.IgnoreWarnings {
+		for name, state := range s.QStatus.PluginState {
+			if state.Health == plugin.HealthUnhealthy {
+				failures = append(failures, fmt.Sprintf("plugin %s: unhealthy", name))
+			}
+		}
+	}
+	if c := s.QStatus.Console; c != nil && !c.Ready {
+		failures = append(failures, fmt.Sprintf("console %s: %s", c.Endpoint, c.Error))
+	}
+	sort.Strings(failures)
+	s.Failures = failures
+	return len(failures) == 0
 }
 
 func (k *K8sStatusCollector) status(ctx context.Context) *Status {
@@ -88,6 +122,9 @@ func (k *K8sStatusCollector) status(ctx context.Context) *Status {
 	if err := k.quchengStatus(ctx, status); err != nil {
 		k.option.Log.Errorf("failed to get qucheng status: %v", err)
 	}
+	if status.QStatus.hasRelease(common.DefaultQuchengName) {
+		status.QStatus.Console = k.consoleStatus(ctx)
+	}
 	return status
 }
 
@@ -126,6 +163,9 @@ func (k *K8sStatusCollector) deploymentStatus(ctx context.Context, ns, name, ali
 	}
 
 	stateCount.Desired = int(d.Status.Replicas)
+	if d.Spec.Replicas != nil {
+		stateCount.Desired = int(*d.Spec.Replicas)
+	}
 	stateCount.Ready = int(d.Status.ReadyReplicas)
 	stateCount.Available = int(d.Status.AvailableReplicas)
 	stateCount.Unavailable = int(d.Status.UnavailableReplicas)
@@ -150,34 +190,25 @@ func (k *K8sStatusCollector) quchengStatus(ctx context.Context, status *Status)
 	k.deploymentStatus(ctx, "kube-system", "coredns", "coredns", "k8s", status)
 	k.deploymentStatus(ctx, "kube-system", "metrics-server", "metrics-server", "k8s", status)
 	k.deploymentStatus(ctx, "kube-system", "local-path-provisioner", "local-path-provisioner", "k8s", status)
-	// 业务层
-	k.deploymentStatus(ctx, common.GetDefaultSystemNamespace(true), common.DefaultQuchengName, common.DefaultQuchengName, "", status)
-	// 数据库
-	k.deploymentStatus(ctx, common.GetDefaultSystemNamespace(true), common.DefaultDBName, common.DefaultDBName, "", status)
-
 	// 插件状态
-	plugins, _ := plugin.GetMaps()
-	for _, p := range plugins {
-		k.quchengPluginStatus(ctx, p, status)
-	}
-	return nil
-}
-
-func (k *K8sStatusCollector) quchengPluginStatus(ctx context.Context, p plugin.Meta, status *Status) error {
-	k.option.Log.Debugf("check plugin %s status", p.Type)
-	stateCount := PodStateCount{Type: "Plugin"}
-	_, err := k.client.GetSecret(ctx, common.GetDefaultSystemNamespace(true), "qc-plugin-"+p.Type, metav1.GetOptions{})
+	states, err := plugin.GetStates(ctx, k.client)
 	if err != nil {
-		stateCount.Disabled = true
-	} else {
-		stateCount.Disabled = false
-		if p.Type == "ingress" {
-			k.deploymentStatus(ctx, common.GetDefaultSystemNamespace(true), fmt.Sprintf("ingress-%s", common.DefaultIngressName), common.DefaultIngressName, "", status)
-		} else if p.Type == "cne-operator" {
-			k.deploymentStatus(ctx, common.GetDefaultSystemNamespace(true), common.DefaultCneOperatorName, common.DefaultCneOperatorName, "", status)
+		k.option.Log.Debugf("get plugin status failed: %v", err)
+	}
+	releases := map[string]bool{common.DefaultQuchengName: true, common.DefaultCneOperatorName: true}
+	for _, state := range states {
+		status.QStatus.PluginState[state.Type] = state
+		if state.Installed {
+			releases[state.Type] = true
 		}
 	}
-	status.QStatus.PluginState[p.Type] = stateCount
+	// 业务层及插件, 按 helm release 发现工作负载
+	if err := k.componentsStatus(ctx, common.GetDefaultSystemNamespace(true), releases, status); err != nil {
+		return err
+	}
+	if !status.QStatus.hasRelease(common.DefaultQuchengName) {
+		status.QStatus.PodState[common.DefaultQuchengName] = PodStateCount{Type: "Deployment", Disabled: true}
+	}
 	return nil
 }
 
@@ -221,3 +252,76 @@ func (k *K8sStatusCollector) nodesStatus(ctx context.Context, status *Status) er
 	}
 	return nil
 }
+
+// consoleStatus 通过 qucheng service 的 nodeport 检查控制台, 不依赖域名解析.
+// 无 nodeport 时使用配置的域名, 均无法确定时跳过检查
+func (k *K8sStatusCollector) consoleStatus(ctx context.Context) *ConsoleState {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		k.option.Log.Debugf("load config failed: %v", err)
+	}
+	var nodes []corev1.Node
+	if list, err := k.client.ListNodes(ctx, metav1.ListOptions{}); err == nil {
+		nodes = list.Items
+	}
+	svc, err := k.client.GetService(ctx, common.GetDefaultSystemNamespace(true), common.DefaultQuchengName, metav1.GetOptions{})
+	if err != nil {
+		k.option.Log.Debugf("get %s service failed: %v", common.DefaultQuchengName, err)
+	}
+	endpoint := consoleEndpoint(svc, nodes, cfg.Cluster.InitNode, cfg.Domain, len(cfg.TLS.SecretName) > 0)
+	if len(endpoint) == 0 {
+		k.option.Log.Debugf("console endpoint unknown, skip console check")
+		return nil
+	}
+	c := &ConsoleState{Endpoint: endpoint}
+	client := req.C().SetLogger(nil).SetUserAgent(common.GetUG()).SetTimeout(5 * time.Second)
+	resp, err := client.R().Get(c.Endpoint)
+	switch {
+	case err != nil:
+		c.Error = err.Error()
+	case resp.StatusCode >= http.StatusInternalServerError:
+		c.Error = resp.Status
+	default:
+		c.Ready = true
+	}
+	return c
+}
+
+// consoleEndpoint 优先使用 nodeport 及节点内网 ip (init 节点优先), 否则使用控制台域名
+func consoleEndpoint(svc *corev1.Service, nodes []corev1.Node, initNode, domain string, tls bool) string {
+	var nodePort int32
+	if svc != nil {
+		for _, p := range svc.Spec.Ports {
+			if p.NodePort > 0 {
+				nodePort = p.NodePort
+				break
+			}
+		}
+	}
+	if nodePort > 0 {
+		ip := ""
+		for _, node := range nodes {
+			for _, addr := range node.Status.Addresses {
+				if addr.Type != corev1.NodeInternalIP {
+					continue
+				}
+				if addr.Address == initNode || len(ip) == 0 {
+					ip = addr.Address
+				}
+			}
+		}
+		if len(ip) > 0 {
+			return fmt.Sprintf("http://%s:%d", ip, nodePort)
+		}
+	}
+	if len(domain) == 0 {
+		return ""
+	}
+	if kutil.IsLegalDomain(domain) {
+		return "https://" + domain
+	}
+	if tls {
+		return "https://console." + domain
+	}
+	return "http://console." + domain
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package status
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestConsoleEndpoint(t *testing.T) {
+	svc := &corev1.Service{Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80, NodePort: 32379}}}}
+	node := func(ip string) corev1.Node {
+		return corev1.Node{Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeExternalIP, Address: "1.1.1.1"},
+			{Type: corev1.NodeInternalIP, Address: ip},
+		}}}
+	}
+	nodes := []corev1.Node{node("10.0.0.1"), node("10.0.0.2")}
+	tests := []struct {
+		name     string
+		svc      *corev1.Service
+		nodes    []corev1.Node
+		initNode string
+		domain   string
+		tls      bool
+		want     string
+	}{
+		{name: "nodeport first node", svc: svc, nodes: nodes, want: "http://10.0.0.1:32379"},
+		{name: "nodeport init node", svc: svc, nodes: nodes, initNode: "10.0.0.2", want: "http://10.0.0.2:32379"},
+		{name: "no nodeport custom domain", nodes: nodes, domain: "example.com", want: "http://console.example.com"},
+		{name: "custom domain with tls", domain: "example.com", tls: true, want: "https://console.example.com"},
+		{name: "managed domain", svc: svc, domain: "demo.corp.cc", want: "https://demo.corp.cc"},
+		{name: "unknown", svc: svc, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := consoleEndpoint(tt.svc, tt.nodes, tt.initNode, tt.domain, tt.tls); got != tt.want {
+				t.Fatalf("consoleEndpoint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
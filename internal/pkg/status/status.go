@@ -9,12 +9,15 @@ package status
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 
 	"github.com/easysoft/qcadmin/common"
 	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/plugin"
 	"github.com/easysoft/qcadmin/internal/pkg/util/kutil"
 	"github.com/easysoft/qcadmin/internal/pkg/util/output"
 	"github.com/ergoapi/util/color"
@@ -27,10 +30,14 @@ type MapMapCount map[string]MapCount
 
 type PodStateMap map[string]PodStateCount
 
+type PluginStateMap map[string]plugin.State
+
 type Status struct {
 	output     string     `json:"-" yaml:"-"`
 	KubeStatus KubeStatus `json:"k8s" yaml:"k8s"`
 	QStatus    QStatus    `json:"qucheng" yaml:"qucheng"`
+	// Failures 未就绪的组件
+	Failures []string `json:"failures,omitempty" yaml:"failures,omitempty"`
 }
 
 type KubeStatus struct {
@@ -56,12 +63,29 @@ type PodStateCount struct {
 	// Unavailable is the number of unavailable pods
 	Unavailable int `json:"unavailable,omitempty" yaml:"unavailable,omitempty"`
 
+	// Release is the helm release which owns the workload
+	Release string `json:"release,omitempty" yaml:"release,omitempty"`
+
+	// Restarts is the total container restarts of the pods
+	Restarts int `json:"restarts,omitempty" yaml:"restarts,omitempty"`
+
+	// Warnings is the recent warning events of the workload and its pods
+	Warnings []string `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+
 	Disabled bool `json:"disabled" yaml:"disabled"`
 }
 
 type QStatus struct {
-	PodState    PodStateMap `json:"service,omitempty" yaml:"service,omitempty"`
-	PluginState PodStateMap `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	PodState    PodStateMap    `json:"service,omitempty" yaml:"service,omitempty"`
+	PluginState PluginStateMap `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	Console     *ConsoleState  `json:"console,omitempty" yaml:"console,omitempty"`
+}
+
+// ConsoleState 控制台 http 检查结果
+type ConsoleState struct {
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	Ready    bool   `json:"ready" yaml:"ready"`
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
 }
 
 func newStatus(output string) *Status {
@@ -74,7 +98,7 @@ func newStatus(output string) *Status {
 		},
 		QStatus: QStatus{
 			PodState:    PodStateMap{},
-			PluginState: PodStateMap{},
+			PluginState: PluginStateMap{},
 		},
 	}
 }
@@ -112,12 +136,12 @@ func (s *Status) Format() error {
 		fmt.Fprintf(w, "Qucheng Status: \n")
 		if s.QStatus.PodState["qucheng"].Disabled {
 			fmt.Fprintf(w, "  %s\t%s\n", "status", color.SBlue("disabled"))
+			s.writeFailures(w)
 			w.Flush()
 			return output.EncodeText(os.Stdout, buf.Bytes())
 		}
 		cfg, _ := config.LoadConfig()
 		domain := ""
-		loginIP := exnet.LocalIPs()[0]
 		if cfg != nil {
 			domain = cfg.Domain
 		}
@@ -127,39 +151,73 @@ func (s *Status) Format() error {
 				domain = fmt.Sprintf("http://console.%s", domain)
 			}
 			consoleURL = domain
-		} else {
-			consoleURL = fmt.Sprintf("http://%s:32379", loginIP)
+		} else if s.QStatus.Console != nil {
+			consoleURL = s.QStatus.Console.Endpoint
+		} else if ips := exnet.LocalIPs(); len(ips) > 0 {
+			consoleURL = fmt.Sprintf("http://%s:32379", ips[0])
 		}
 		fmt.Fprintf(w, "  namespace:\t%s\n", color.SBlue(common.GetDefaultSystemNamespace(true)))
 		fmt.Fprintf(w, "  console:      %s(%s/%s)\n", color.SGreen(consoleURL), color.SGreen(common.QuchengDefaultUser), color.SGreen(cfg.ConsolePassword))
 		quchengOK := true
 		fmt.Fprintf(w, "  component status: \n")
-		for name, state := range s.QStatus.PodState {
+		for _, name := range s.QStatus.PodState.names() {
+			state := s.QStatus.PodState[name]
 			if state.Disabled {
 				fmt.Fprintf(w, "    %s\t%s\n", name, color.SBlue("disabled"))
-			} else {
-				if state.Available > 0 {
-					fmt.Fprintf(w, "    %s\t%s\n", name, color.SGreen("ok"))
-				} else {
-					fmt.Fprintf(w, "    %s\t%s\n", name, color.SRed("warn"))
-					quchengOK = false
-				}
+				continue
+			}
+			health := color.SGreen("ok")
+			if state.Available < state.Desired || (state.Desired > 0 && state.Available == 0) {
+				health = color.SRed("warn")
+				quchengOK = false
+			}
+			fmt.Fprintf(w, "    %s\t%s\t%s %d/%d\trestarts: %d\n", name, health, strings.ToLower(state.Type), state.Ready, state.Desired, state.Restarts)
+			for _, e := range state.Warnings {
+				fmt.Fprintf(w, "      %s\n", color.SYellow("%s", e))
 			}
 		}
 		fmt.Fprintf(w, "  plugin status: \n")
 		for name, state := range s.QStatus.PluginState {
-			if state.Disabled {
+			switch state.Health {
+			case plugin.HealthDisabled:
 				fmt.Fprintf(w, "    %s\t%s\n", name, color.SBlue("disabled"))
-			} else {
+			case plugin.HealthUnhealthy:
+				fmt.Fprintf(w, "    %s\t%s\n", name, color.SRed("warn"))
+				quchengOK = false
+			default:
 				fmt.Fprintf(w, "    %s\t%s\n", name, color.SGreen("enabled"))
 			}
 		}
+		if s.QStatus.Console != nil && !s.QStatus.Console.Ready {
+			quchengOK = false
+		}
 		if quchengOK {
 			fmt.Fprintf(w, "  %s\t%s\n", "status", color.SGreen("health"))
 		} else {
 			fmt.Fprintf(w, "  %s\t%s\n", "status", color.SRed("unhealth"))
 		}
+		s.writeFailures(w)
 		w.Flush()
 		return output.EncodeText(os.Stdout, buf.Bytes())
 	}
 }
+
+// names 按名称排序
+func (m PodStateMap) names() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Status) writeFailures(w io.Writer) {
+	if len(s.Failures) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nNot Ready: \n")
+	for _, f := range s.Failures {
+		fmt.Fprintf(w, "  %s\n", color.SRed(f))
+	}
+}
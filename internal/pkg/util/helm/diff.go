@@ -0,0 +1,165 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package helm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+)
+
+const diffContextLines = 3
+
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// ManifestDiff resource level change between two rendered manifests
+type ManifestDiff struct {
+	Resource string
+	Action   string
+	Diff     string
+}
+
+type manifestHead struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+}
+
+func splitResources(manifest string) map[string]string {
+	resources := map[string]string{}
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		var head manifestHead
+		if err := yaml.Unmarshal([]byte(doc), &head); err != nil || head.Kind == "" {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s", head.Kind, head.Metadata.Name)
+		if head.Metadata.Namespace != "" {
+			key = fmt.Sprintf("%s/%s", head.Metadata.Namespace, key)
+		}
+		resources[key] = strings.TrimSpace(doc)
+	}
+	return resources
+}
+
+// DiffManifests compare two rendered manifests, resources are sorted by name
+func DiffManifests(oldManifest, newManifest string) []ManifestDiff {
+	oldResources := splitResources(oldManifest)
+	newResources := splitResources(newManifest)
+	keys := map[string]bool{}
+	for k := range oldResources {
+		keys[k] = true
+	}
+	for k := range newResources {
+		keys[k] = true
+	}
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	var result []ManifestDiff
+	for _, k := range sorted {
+		o, inOld := oldResources[k]
+		n, inNew := newResources[k]
+		switch {
+		case !inOld:
+			result = append(result, ManifestDiff{Resource: k, Action: DiffAdded, Diff: prefixLines(n, "+ ")})
+		case !inNew:
+			result = append(result, ManifestDiff{Resource: k, Action: DiffRemoved, Diff: prefixLines(o, "- ")})
+		case o != n:
+			result = append(result, ManifestDiff{Resource: k, Action: DiffChanged, Diff: diffLines(strings.Split(o, "\n"), strings.Split(n, "\n"))})
+		}
+	}
+	return result
+}
+
+func prefixLines(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = prefix + l
+	}
+	return strings.Join(lines, "\n")
+}
+
+// diffLines line diff based on lcs, only changed lines with context are kept
+func diffLines(a, b []string) string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, line{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, line{'+', b[j]})
+	}
+	keep := make([]bool, len(lines))
+	for idx, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for k := idx - diffContextLines; k <= idx+diffContextLines; k++ {
+			if k >= 0 && k < len(lines) {
+				keep[k] = true
+			}
+		}
+	}
+	var out []string
+	skipped := false
+	for idx, l := range lines {
+		if !keep[idx] {
+			skipped = true
+			continue
+		}
+		if skipped && len(out) > 0 {
+			out = append(out, "  ...")
+		}
+		skipped = false
+		out = append(out, fmt.Sprintf("%c %s", l.op, l.text))
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package helm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffManifests(t *testing.T) {
+	oldManifest := `---
+# Source: a/templates/svc.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: ClusterIP
+---
+# Source: a/templates/cm.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: old
+`
+	newManifest := `---
+# Source: a/templates/svc.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: NodePort
+---
+# Source: a/templates/deploy.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`
+	diffs := DiffManifests(oldManifest, newManifest)
+	want := map[string]string{
+		"ConfigMap/old":  DiffRemoved,
+		"Deployment/web": DiffAdded,
+		"Service/web":    DiffChanged,
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("expected %d diffs, got %d: %+v", len(want), len(diffs), diffs)
+	}
+	for _, d := range diffs {
+		if want[d.Resource] != d.Action {
+			t.Errorf("resource %s: expected %s, got %s", d.Resource, want[d.Resource], d.Action)
+		}
+		if d.Resource == "Service/web" {
+			if !strings.Contains(d.Diff, "-   type: ClusterIP") || !strings.Contains(d.Diff, "+   type: NodePort") {
+				t.Errorf("unexpected service diff:\n%s", d.Diff)
+			}
+		}
+	}
+	if diffs := DiffManifests(oldManifest, oldManifest); len(diffs) != 0 {
+		t.Errorf("expected no diff, got %+v", diffs)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package helm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/releaseutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// ReleaseError failed release operation, with release status and failing resources
+type ReleaseError struct {
+	Op        string
+	Release   string
+	Namespace string
+	// Status release status after the operation, e.g. failed, pending-install
+	Status string
+	// Resources not ready workloads, e.g. Deployment/cne-operator: 0/1 ready
+	Resources []string
+	Err       error
+}
+
+func (e *ReleaseError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s release %s/%s failed", e.Op, e.Namespace, e.Release)
+	if len(e.Status) > 0 {
+		fmt.Fprintf(&b, " (status: %s)", e.Status)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	if len(e.Resources) > 0 {
+		fmt.Fprintf(&b, "\n\tfailing resources:\n\t  %s", strings.Join(e.Resources, "\n\t  "))
+	}
+	return b.String()
+}
+
+func (e *ReleaseError) Unwrap() error {
+	return e.Err
+}
+
+// releaseError collect release status and not ready workloads of the failed release
+func (c Client) releaseError(op, name string, err error) error {
+	re := &ReleaseError{Op: op, Release: name, Namespace: c.Namespace, Err: err}
+	rel, gerr := c.GetDetail(name)
+	if gerr != nil || rel == nil {
+		return re
+	}
+	if rel.Info != nil {
+		re.Status = rel.Info.Status.String()
+	}
+	re.Resources = c.failingResources(rel.Namespace, rel.Manifest)
+	return re
+}
+
+func (c Client) failingResources(ns, manifest string) []string {
+	kubeClient, err := c.actionConfig.KubernetesClientSet()
+	if err != nil {
+		return nil
+	}
+	ctx := context.TODO()
+	var failing []string
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		var head manifestHead
+		if err := yaml.Unmarshal([]byte(doc), &head); err != nil {
+			continue
+		}
+		rns := head.Metadata.Namespace
+		if len(rns) == 0 {
+			rns = ns
+		}
+		name := head.Metadata.Name
+		var desired, ready int32
+		switch head.Kind {
+		case "Deployment":
+			d, err := kubeClient.AppsV1().Deployments(rns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				failing = append(failing, fmt.Sprintf("%s/%s: %v", head.Kind, name, err))
+				continue
+			}
+			desired, ready = 1, d.Status.ReadyReplicas
+			if d.Spec.Replicas != nil {
+				desired = *d.Spec.Replicas
+			}
+		case "StatefulSet":
+			s, err := kubeClient.AppsV1().StatefulSets(rns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				failing = append(failing, fmt.Sprintf("%s/%s: %v", head.Kind, name, err))
+				continue
+			}
+			desired, ready = 1, s.Status.ReadyReplicas
+			if s.Spec.Replicas != nil {
+				desired = *s.Spec.Replicas
+			}
+		case "DaemonSet":
+			d, err := kubeClient.AppsV1().DaemonSets(rns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				failing = append(failing, fmt.Sprintf("%s/%s: %v", head.Kind, name, err))
+				continue
+			}
+			desired, ready = d.Status.DesiredNumberScheduled, d.Status.NumberReady
+		case "Job":
+			j, err := kubeClient.BatchV1().Jobs(rns).Get(ctx, name, metav1.GetOptions{})
+			if err == nil && j.Status.Failed > 0 {
+				failing = append(failing, fmt.Sprintf("%s/%s: %d failed", head.Kind, name, j.Status.Failed))
+			}
+			continue
+		default:
+			continue
+		}
+		if ready < desired {
+			failing = append(failing, fmt.Sprintf("%s/%s: %d/%d ready", head.Kind, name, ready, desired))
+		}
+	}
+	return failing
+}
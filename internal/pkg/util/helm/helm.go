@@ -25,29 +25,47 @@ import (
 	"github.com/gofrs/flock"
 	"helm.sh/helm/v3/cmd/helm/search"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/helmpath"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
 	"helm.sh/helm/v3/pkg/repo"
 	"helm.sh/helm/v3/pkg/storage/driver"
 	"sigs.k8s.io/yaml"
 )
 
 const (
-	helmDriver = "secrets"
+	helmDriver     = "secrets"
+	defaultTimeout = 5 * time.Minute
 )
 
 func nolog(format string, v ...interface{}) {}
 
+// ProgressFunc receive progress messages of release operations
+type ProgressFunc func(format string, v ...interface{})
+
 type Config struct {
 	Namespace string
+	// Atomic rollback the release when install or upgrade failed, implies Wait
+	Atomic bool
+	// Wait wait until all resources are ready
+	Wait bool
+	// Timeout time to wait for kubernetes resources, default 5m
+	Timeout time.Duration
+	// Progress receive progress messages, including resources waited for
+	Progress ProgressFunc
 }
 
 type Client struct {
 	actionConfig *action.Configuration
 	Namespace    string
+	atomic       bool
+	wait         bool
+	timeout      time.Duration
+	progress     ProgressFunc
 	settings     *cli.EnvSettings
 	log          log.Logger
 }
@@ -59,12 +77,22 @@ func NewClient(config *Config) (*Client, error) {
 	settings.KubeConfig = common.GetKubeConfig()
 	settings.RepositoryCache = common.GetDefaultCacheDir()
 	client.settings = settings
+	client.progress = nolog
+	if config.Progress != nil {
+		client.progress = config.Progress
+	}
 	actionConfig := &action.Configuration{}
-	if err := actionConfig.Init(settings.RESTClientGetter(), config.Namespace, helmDriver, nolog); err != nil {
+	if err := actionConfig.Init(settings.RESTClientGetter(), config.Namespace, helmDriver, action.DebugLog(client.progress)); err != nil {
 		return nil, err
 	}
 	client.actionConfig = actionConfig
 	client.Namespace = config.Namespace
+	client.atomic = config.Atomic
+	client.wait = config.Wait || config.Atomic
+	client.timeout = config.Timeout
+	if client.timeout == 0 {
+		client.timeout = defaultTimeout
+	}
 	client.log = log.GetInstance()
 	return client, nil
 }
@@ -104,33 +132,15 @@ func (c Client) GetDetail(name string) (*release.Release, error) {
 }
 
 func (c Client) Upgrade(name, repoName, chartName, chartVersion string, values map[string]interface{}) (*release.Release, error) {
-	repos, err := c.ListRepo()
+	rp, err := c.getRepo(repoName)
 	if err != nil {
 		return nil, err
 	}
-	var rp *repo.Entry
-	for _, r := range repos {
-		if r.Name == repoName {
-			rp = r
-		}
-	}
-	if rp == nil {
-		return nil, errors.New("get chart detail failed, repo not found")
-	}
-
-	histClient := action.NewHistory(c.actionConfig)
-	histClient.Max = 1
-	if _, err := histClient.Run(name); err == driver.ErrReleaseNotFound {
-		// If a release does not exist, install it.
-		return c.Install(name, repoName, chartName, chartVersion, values)
-	}
 
 	client := action.NewUpgrade(c.actionConfig)
-	client.Namespace = c.Namespace
 	client.RepoURL = rp.URL
 	client.Username = rp.Username
 	client.Password = rp.Password
-	client.DryRun = false
 	client.ChartPathOptions.InsecureSkipTLSverify = true
 	if len(chartVersion) > 0 {
 		client.ChartPathOptions.Version = chartVersion
@@ -143,36 +153,45 @@ func (c Client) Upgrade(name, repoName, chartName, chartVersion string, values m
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("locate chart %s failed: %v", chartName, err))
 	}
-	c.log.Infof("chart name %s path: %s", chartName, p)
-	ct, err := loader.Load(p)
+	c.log.Debugf("chart name %s path: %s", chartName, p)
+	return c.UpgradeChart(name, p, values)
+}
+
+// UpgradeChart install or upgrade the release with a local chart archive or directory
+func (c Client) UpgradeChart(name, chartPath string, values map[string]interface{}) (*release.Release, error) {
+	ct, err := loader.Load(chartPath)
 	if err != nil {
-		return nil, errors.Wrap(err, fmt.Sprintf("load chart %s failed: %v", chartName, err))
+		return nil, errors.Wrap(err, fmt.Sprintf("load chart %s failed: %v", chartPath, err))
+	}
+	histClient := action.NewHistory(c.actionConfig)
+	histClient.Max = 1
+	if _, err := histClient.Run(name); err == driver.ErrReleaseNotFound {
+		// If a release does not exist, install it.
+		return c.installChart(name, ct, values)
 	}
+
+	client := action.NewUpgrade(c.actionConfig)
+	client.Namespace = c.Namespace
+	client.DryRun = false
+	client.Atomic = c.atomic
+	client.Wait = c.wait
+	client.Timeout = c.timeout
+	c.progress("upgrade release %s with chart %s-%s", name, ct.Metadata.Name, ct.Metadata.Version)
 	// TODO 获取之前参数，并且更新参数
 	release, err := client.Run(name, ct, values)
 	if err != nil {
-		return release, errors.Wrap(err, fmt.Sprintf("upgrade tool %s with chart %s failed: %v", name, chartName, err))
+		return release, c.releaseError("upgrade", name, err)
 	}
+	c.progress("upgrade release %s success", name)
 	return release, nil
 }
 
 func (c Client) Install(name, repoName, chartName, chartVersion string, values map[string]interface{}) (*release.Release, error) {
-	repos, err := c.ListRepo()
+	rp, err := c.getRepo(repoName)
 	if err != nil {
 		return nil, err
 	}
-	var rp *repo.Entry
-	for _, r := range repos {
-		if r.Name == repoName {
-			rp = r
-		}
-	}
-	if rp == nil {
-		return nil, errors.New("get chart detail failed, repo not found")
-	}
 	client := action.NewInstall(c.actionConfig)
-	client.ReleaseName = name
-	client.Namespace = c.Namespace
 	client.RepoURL = rp.URL
 	client.Username = rp.Username
 	client.Password = rp.Password
@@ -188,13 +207,61 @@ func (c Client) Install(name, repoName, chartName, chartVersion string, values m
 	if err != nil {
 		return nil, fmt.Errorf("load chart %s failed: %v", chartName, err)
 	}
+	return c.installChart(name, ct, values)
+}
+
+func (c Client) installChart(name string, ct *chart.Chart, values map[string]interface{}) (*release.Release, error) {
+	client := action.NewInstall(c.actionConfig)
+	client.ReleaseName = name
+	client.Namespace = c.Namespace
+	client.Atomic = c.atomic
+	client.Wait = c.wait
+	client.Timeout = c.timeout
+	c.progress("install release %s with chart %s-%s", name, ct.Metadata.Name, ct.Metadata.Version)
 	re, err := client.Run(ct, values)
 	if err != nil {
-		return re, errors.Wrap(err, fmt.Sprintf("install %s with chart %s failed: %v", name, chartName, err))
+		return re, c.releaseError("install", name, err)
 	}
+	c.progress("install release %s success", name)
 	return re, nil
 }
 
+// DryRunUpgrade render the release with the given values, nothing will be applied
+func (c Client) DryRunUpgrade(name, repoName, chartName, chartVersion string, values map[string]interface{}) (*release.Release, error) {
+	rp, err := c.getRepo(repoName)
+	if err != nil {
+		return nil, err
+	}
+	pathOptions := action.ChartPathOptions{
+		RepoURL:               rp.URL,
+		Username:              rp.Username,
+		Password:              rp.Password,
+		InsecureSkipTLSverify: true,
+		Version:               chartVersion,
+	}
+	p, err := pathOptions.LocateChart(chartName, c.settings)
+	if err != nil {
+		return nil, errors.Wrapf(err, "locate chart %s failed", chartName)
+	}
+	ct, err := loader.Load(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load chart %s failed", chartName)
+	}
+	histClient := action.NewHistory(c.actionConfig)
+	histClient.Max = 1
+	if _, err := histClient.Run(name); err == driver.ErrReleaseNotFound {
+		client := action.NewInstall(c.actionConfig)
+		client.ReleaseName = name
+		client.Namespace = c.Namespace
+		client.DryRun = true
+		return client.Run(ct, values)
+	}
+	client := action.NewUpgrade(c.actionConfig)
+	client.Namespace = c.Namespace
+	client.DryRun = true
+	return client.Run(name, ct, values)
+}
+
 func (c Client) UpdateRepo() error {
 	if !kutil.NeedCacheHelmFile() {
 		return nil
@@ -238,6 +305,20 @@ func (c Client) updateCharts(repos []*repo.ChartRepository) {
 	c.log.Debug("Update Complete. ⎈ Happy Helming!⎈ ")
 }
 
+// getRepo find the repo entry by name
+func (c Client) getRepo(repoName string) (*repo.Entry, error) {
+	repos, err := c.ListRepo()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range repos {
+		if r.Name == repoName {
+			return r, nil
+		}
+	}
+	return nil, errors.Errorf("get chart detail failed, repo %s not found", repoName)
+}
+
 func (c Client) ListRepo() ([]*repo.Entry, error) {
 	var repos []*repo.Entry
 	f, err := repo.LoadFile(c.settings.RepositoryConfig)
@@ -351,6 +432,22 @@ func (c Client) AddRepo(name, url, username, password string) error {
 	return nil
 }
 
+// InitRepo add the repo if not exists, then update the index of all repos
+func (c Client) InitRepo(name, url string) error {
+	repos, _ := c.ListRepo()
+	for _, r := range repos {
+		if r.Name == name {
+			c.log.Debugf("found repo %s, will update helm repo", name)
+			return c.UpdateRepo()
+		}
+	}
+	c.log.Debugf("not found repo %s, will add helm repo", name)
+	if err := c.AddRepo(name, url, "", ""); err != nil {
+		return err
+	}
+	return c.UpdateRepo()
+}
+
 func (c Client) GetCharts(repoName, name string) ([]*search.Result, error) {
 	charts, err := c.ListCharts(repoName, name, true)
 	if err != nil {
@@ -394,8 +491,42 @@ func (c Client) GetLastCharts(repoName, name string) ([]*search.Result, error) {
 	return data, nil
 }
 
+// Pull 下载 chart 包到 destDir, 返回 chart 包路径
+func (c Client) Pull(repoName, chartName, chartVersion, destDir string) (string, error) {
+	rp, err := c.getRepo(repoName)
+	if err != nil {
+		return "", err
+	}
+	pathOptions := action.ChartPathOptions{
+		RepoURL:               rp.URL,
+		Username:              rp.Username,
+		Password:              rp.Password,
+		InsecureSkipTLSverify: true,
+		Version:               chartVersion,
+	}
+	p, err := pathOptions.LocateChart(chartName, c.settings)
+	if err != nil {
+		return "", errors.Wrapf(err, "locate chart %s failed", chartName)
+	}
+	if err := os.MkdirAll(destDir, common.FileMode0755); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(destDir, filepath.Base(p))
+	if err := os.WriteFile(dest, data, common.FileMode0644); err != nil {
+		return "", err
+	}
+	c.progress("pull chart %s to %s", chartName, dest)
+	return dest, nil
+}
+
 func (c Client) Uninstall(name string) (*release.UninstallReleaseResponse, error) {
 	client := action.NewUninstall(c.actionConfig)
+	client.Wait = c.wait
+	client.Timeout = c.timeout
 	res, err := client.Run(name)
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("uninstall tool %s failed: %v", name, err))
@@ -403,6 +534,30 @@ func (c Client) Uninstall(name string) (*release.UninstallReleaseResponse, error
 	return res, nil
 }
 
+// History release revisions, latest first
+func (c Client) History(name string, max int) ([]*release.Release, error) {
+	client := action.NewHistory(c.actionConfig)
+	client.Max = max
+	res, err := client.Run(name)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("get history %s failed: %v", name, err))
+	}
+	releaseutil.Reverse(res, releaseutil.SortByRevision)
+	return res, nil
+}
+
+// Rollback roll back the release to the revision, 0 means the previous revision
+func (c Client) Rollback(name string, revision int) error {
+	client := action.NewRollback(c.actionConfig)
+	client.Version = revision
+	client.Wait = c.wait
+	client.Timeout = c.timeout
+	if err := client.Run(name); err != nil {
+		return c.releaseError("rollback", name, err)
+	}
+	return nil
+}
+
 func (c Client) GetValues(name string) (map[string]interface{}, error) {
 	client := action.NewGetValues(c.actionConfig)
 	res, err := client.Run(name)
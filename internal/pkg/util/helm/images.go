@@ -0,0 +1,70 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package helm
+
+import (
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+)
+
+// RenderChart 仅在本地渲染 chart, 不访问集群
+func RenderChart(ct *chart.Chart, namespace string, values map[string]interface{}) (string, error) {
+	client := action.NewInstall(&action.Configuration{})
+	client.DryRun = true
+	client.ClientOnly = true
+	client.Replace = true
+	client.IncludeCRDs = true
+	client.ReleaseName = ct.Metadata.Name
+	client.Namespace = namespace
+	client.KubeVersion = &chartutil.KubeVersion{Version: "v1.24.15", Major: "1", Minor: "24"}
+	rel, err := client.Run(ct, values)
+	if err != nil {
+		return "", errors.Wrapf(err, "render chart %s failed", ct.Metadata.Name)
+	}
+	return rel.Manifest, nil
+}
+
+// ManifestImages 提取渲染结果中的镜像, 已去重排序
+func ManifestImages(manifest string) []string {
+	found := map[string]bool{}
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		var obj interface{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			continue
+		}
+		collectImages(obj, found)
+	}
+	images := make([]string, 0, len(found))
+	for img := range found {
+		images = append(images, img)
+	}
+	sort.Strings(images)
+	return images
+}
+
+func collectImages(obj interface{}, found map[string]bool) {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if s, ok := val.(string); ok && k == "image" && len(s) > 0 {
+				found[s] = true
+				continue
+			}
+			collectImages(val, found)
+		}
+	case []interface{}:
+		for _, val := range v {
+			collectImages(val, found)
+		}
+	}
+}
@@ -7,10 +7,34 @@
 package helm
 
 import (
+	"os"
+
 	"github.com/cockroachdb/errors"
 	"helm.sh/helm/v3/pkg/strvals"
+	"sigs.k8s.io/yaml"
 )
 
+// ParseValues merge values files and --set values, later one wins
+func ParseValues(files, values []string) (map[string]interface{}, error) {
+	base := map[string]interface{}{}
+	for _, f := range files {
+		current := map[string]interface{}{}
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading values file %s", f)
+		}
+		if err := yaml.Unmarshal(b, &current); err != nil {
+			return nil, errors.Wrapf(err, "failed parsing values file %s", f)
+		}
+		base = MergeMaps(base, current)
+	}
+	setValues, err := MergeValues(values)
+	if err != nil {
+		return nil, err
+	}
+	return MergeMaps(base, setValues), nil
+}
+
 func MergeValues(values []string) (map[string]interface{}, error) {
 	base := map[string]interface{}{}
 	for _, value := range values {
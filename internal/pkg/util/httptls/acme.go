@@ -0,0 +1,318 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package httptls
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	SolverHTTP01 = "http01"
+	SolverDNS01  = "dns01"
+
+	acmeDNSSecretName = "quickon-acme-dns"
+)
+
+var (
+	certificateGVR   = schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+	clusterIssuerGVR = schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "clusterissuers"}
+)
+
+// ACMEOptions cert-manager ClusterIssuer 配置
+type ACMEOptions struct {
+	Email         string
+	Server        string
+	SkipTLSVerify bool
+	Solver        string
+	IngressClass  string
+	DNSProvider   string
+	DNSToken      string
+}
+
+// Validate 校验 acme 配置, 目前 dns01 仅支持 cloudflare
+func (o *ACMEOptions) Validate() error {
+	if len(o.Email) == 0 {
+		return errors.New("acme account email is required")
+	}
+	if len(o.Server) == 0 {
+		o.Server = common.DefaultACMEServer
+	}
+	switch o.Solver {
+	case SolverHTTP01:
+		if len(o.IngressClass) == 0 {
+			o.IngressClass = "nginx"
+		}
+	case SolverDNS01:
+		if o.DNSProvider != "cloudflare" {
+			return errors.Errorf("dns provider %q not support, only cloudflare", o.DNSProvider)
+		}
+		if len(o.DNSToken) == 0 {
+			return errors.New("dns provider api token is required")
+		}
+	default:
+		return errors.Errorf("acme solver %q not support, only http01 or dns01", o.Solver)
+	}
+	return nil
+}
+
+// DNSNames 证书域名, 泛域名只能通过 dns01 签发
+func (o *ACMEOptions) DNSNames(domain string) []string {
+	if o.Solver == SolverDNS01 {
+		return []string{domain, "*." + domain}
+	}
+	return []string{fmt.Sprintf("console.%s", domain), fmt.Sprintf("s3.%s", domain)}
+}
+
+func (o *ACMEOptions) solver() map[string]interface{} {
+	if o.Solver == SolverDNS01 {
+		return map[string]interface{}{
+			"dns01": map[string]interface{}{
+				"cloudflare": map[string]interface{}{
+					"apiTokenSecretRef": map[string]interface{}{
+						"name": acmeDNSSecretName,
+						"key":  "api-token",
+					},
+				},
+			},
+		}
+	}
+	return map[string]interface{}{
+		"http01": map[string]interface{}{
+			"ingress": map[string]interface{}{
+				"class": o.IngressClass,
+			},
+		},
+	}
+}
+
+// ApplyClusterIssuer 创建或更新 ClusterIssuer, dns01 凭证保存在系统命名空间
+func (o *ACMEOptions) ApplyClusterIssuer(ctx context.Context, client *k8s.Client) error {
+	if o.Solver == SolverDNS01 {
+		ns := common.GetDefaultSystemNamespace(true)
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: acmeDNSSecretName, Namespace: ns},
+			StringData: map[string]string{"api-token": o.DNSToken},
+		}
+		if _, err := client.CreateSecret(ctx, ns, secret, metav1.CreateOptions{}); err != nil {
+			if !kerrors.IsAlreadyExists(err) {
+				return errors.Wrap(err, "create dns provider secret failed")
+			}
+			if _, err := client.UpdateSecret(ctx, ns, secret, metav1.UpdateOptions{}); err != nil {
+				return errors.Wrap(err, "update dns provider secret failed")
+			}
+		}
+	}
+	issuer := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "ClusterIssuer",
+		"metadata": map[string]interface{}{
+			"name": common.ACMEIssuerName,
+		},
+		"spec": map[string]interface{}{
+			"acme": map[string]interface{}{
+				"email":         o.Email,
+				"server":        o.Server,
+				"skipTLSVerify": o.SkipTLSVerify,
+				"privateKeySecretRef": map[string]interface{}{
+					"name": common.ACMEIssuerName + "-account",
+				},
+				"solvers": []interface{}{o.solver()},
+			},
+		},
+	}}
+	return applyUnstructured(ctx, client, clusterIssuerGVR, "", issuer)
+}
+
+// ApplyCertificate 仅在系统命名空间申请证书, default 命名空间通过 SyncACMESecret 复制 secret,
+// 避免同一域名重复下单触发 Let's Encrypt 重复证书限制
+func ApplyCertificate(ctx context.Context, client *k8s.Client, dnsNames []string) error {
+	ns := common.GetDefaultSystemNamespace(true)
+	// 清理旧版本在 default 命名空间创建的 Certificate, 保留其 secret 直至被同步覆盖
+	if err := client.DynamicClientset.Resource(certificateGVR).Namespace("default").Delete(ctx, common.ACMETLSSecretName, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrapf(err, "delete certificate default/%s failed", common.ACMETLSSecretName)
+	}
+	names := make([]interface{}, 0, len(dnsNames))
+	for _, n := range dnsNames {
+		names = append(names, n)
+	}
+	cert := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata": map[string]interface{}{
+			"name":      common.ACMETLSSecretName,
+			"namespace": ns,
+		},
+		"spec": map[string]interface{}{
+			"secretName": common.ACMETLSSecretName,
+			"dnsNames":   names,
+			"issuerRef": map[string]interface{}{
+				"kind": "ClusterIssuer",
+				"name": common.ACMEIssuerName,
+			},
+		},
+	}}
+	return applyUnstructured(ctx, client, certificateGVR, ns, cert)
+}
+
+// SyncACMESecret 将系统命名空间签发的证书 secret 复制到 default 命名空间, 内容未变化时跳过
+func SyncACMESecret(ctx context.Context, client *k8s.Client) error {
+	ns := common.GetDefaultSystemNamespace(true)
+	secret, err := client.GetSecret(ctx, ns, common.ACMETLSSecretName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "get tls secret %s/%s failed", ns, common.ACMETLSSecretName)
+	}
+	kp := &KeyPair{Cert: secret.Data[corev1.TLSCertKey], Key: secret.Data[corev1.TLSPrivateKeyKey]}
+	if len(kp.Cert) == 0 || len(kp.Key) == 0 {
+		return errors.Errorf("tls secret %s/%s not issued yet", ns, common.ACMETLSSecretName)
+	}
+	return kp.applySecret(ctx, client, "default", common.ACMETLSSecretName)
+}
+
+func applyUnstructured(ctx context.Context, client *k8s.Client, gvr schema.GroupVersionResource, ns string, obj *unstructured.Unstructured) error {
+	ri := client.DynamicClientset.Resource(gvr).Namespace(ns)
+	old, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "get %s %s failed", obj.GetKind(), obj.GetName())
+		}
+		if _, err := ri.Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+			return errors.Wrapf(err, "create %s %s failed", obj.GetKind(), obj.GetName())
+		}
+		return nil
+	}
+	obj.SetResourceVersion(old.GetResourceVersion())
+	if _, err := ri.Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrapf(err, "update %s %s failed", obj.GetKind(), obj.GetName())
+	}
+	return nil
+}
+
+// CertificateStatus cert-manager Certificate 状态
+type CertificateStatus struct {
+	Namespace   string
+	Name        string
+	DNSNames    []string
+	Ready       bool
+	Reason      string
+	Message     string
+	NotAfter    string
+	RenewalTime string
+}
+
+// GetCertificateStatus 读取 Certificate 的 Ready 条件及有效期
+func GetCertificateStatus(ctx context.Context, client *k8s.Client, ns string) (*CertificateStatus, error) {
+	obj, err := client.DynamicClientset.Resource(certificateGVR).Namespace(ns).Get(ctx, common.ACMETLSSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	cs := &CertificateStatus{Namespace: ns, Name: obj.GetName()}
+	cs.DNSNames, _, _ = unstructured.NestedStringSlice(obj.Object, "spec", "dnsNames")
+	cs.NotAfter, _, _ = unstructured.NestedString(obj.Object, "status", "notAfter")
+	cs.RenewalTime, _, _ = unstructured.NestedString(obj.Object, "status", "renewalTime")
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != "Ready" {
+			continue
+		}
+		cs.Ready = cond["status"] == "True"
+		cs.Reason, _ = cond["reason"].(string)
+		cs.Message, _ = cond["message"].(string)
+	}
+	return cs, nil
+}
+
+// WaitCertificateReady 等待证书签发完成
+func WaitCertificateReady(ctx context.Context, client *k8s.Client, ns string, timeout time.Duration) (*CertificateStatus, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		cs, err := GetCertificateStatus(ctx, client, ns)
+		if err == nil && cs.Ready {
+			return cs, nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return nil, err
+			}
+			return cs, errors.Errorf("certificate %s/%s not ready: %s", ns, cs.Name, cs.Message)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// RenewACMECertificate 触发 cert-manager 重新签发, 与 cmctl renew 一致
+func RenewACMECertificate(ctx context.Context, client *k8s.Client, ns string) error {
+	ri := client.DynamicClientset.Resource(certificateGVR).Namespace(ns)
+	obj, err := ri.Get(ctx, common.ACMETLSSecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	var kept []interface{}
+	for _, c := range conditions {
+		if cond, ok := c.(map[string]interface{}); ok && cond["type"] == "Issuing" {
+			continue
+		}
+		kept = append(kept, c)
+	}
+	kept = append(kept, map[string]interface{}{
+		"type":               "Issuing",
+		"status":             "True",
+		"reason":             "ManuallyTriggered",
+		"message":            "Certificate re-issuance manually triggered",
+		"lastTransitionTime": time.Now().UTC().Format(time.RFC3339),
+	})
+	if err := unstructured.SetNestedSlice(obj.Object, kept, "status", "conditions"); err != nil {
+		return err
+	}
+	_, err = ri.UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	return err
+}
+
+// checkACMECertificate 输出 cert-manager 证书状态, force 时触发重新签发
+func checkACMECertificate(force bool) error {
+	log := log.GetInstance()
+	client, err := k8s.NewSimpleClient(common.GetKubeConfig())
+	if err != nil {
+		return errors.Errorf("load k8s client failed, reason: %v", err)
+	}
+	ctx := context.Background()
+	ns := common.GetDefaultSystemNamespace(true)
+	cs, err := GetCertificateStatus(ctx, client, ns)
+	if err != nil {
+		return errors.Wrapf(err, "get certificate %s/%s failed", ns, common.ACMETLSSecretName)
+	}
+	if force {
+		if err := RenewACMECertificate(ctx, client, ns); err != nil {
+			return errors.Wrapf(err, "renew certificate %s/%s failed", ns, cs.Name)
+		}
+		log.Donef("certificate %s/%s re-issuance triggered, run again after issued to sync it to default namespace", ns, cs.Name)
+		return nil
+	}
+	if !cs.Ready {
+		return errors.Errorf("certificate %s/%s not ready, reason: %s, %s", ns, cs.Name, cs.Reason, cs.Message)
+	}
+	log.Donef("certificate %s/%s ready, dns: %s, expire at %s, renew at %s", ns, cs.Name, strings.Join(cs.DNSNames, ","), cs.NotAfter, cs.RenewalTime)
+	if err := SyncACMESecret(ctx, client); err != nil {
+		return err
+	}
+	log.Donef("sync tls secret %s to default namespace success", common.ACMETLSSecretName)
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package httptls
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestACMEOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ACMEOptions
+		wantErr bool
+		want    ACMEOptions
+	}{
+		{
+			name: "http01 defaults",
+			opts: ACMEOptions{Email: "admin@example.com", Solver: SolverHTTP01},
+			want: ACMEOptions{Email: "admin@example.com", Solver: SolverHTTP01, Server: common.DefaultACMEServer, IngressClass: "nginx"},
+		},
+		{
+			name: "custom server kept",
+			opts: ACMEOptions{Email: "admin@example.com", Solver: SolverHTTP01, Server: "https://pebble:14000/dir", IngressClass: "traefik"},
+			want: ACMEOptions{Email: "admin@example.com", Solver: SolverHTTP01, Server: "https://pebble:14000/dir", IngressClass: "traefik"},
+		},
+		{
+			name: "dns01 cloudflare",
+			opts: ACMEOptions{Email: "admin@example.com", Solver: SolverDNS01, DNSProvider: "cloudflare", DNSToken: "token"},
+			want: ACMEOptions{Email: "admin@example.com", Solver: SolverDNS01, DNSProvider: "cloudflare", DNSToken: "token", Server: common.DefaultACMEServer},
+		},
+		{name: "missing email", opts: ACMEOptions{Solver: SolverHTTP01}, wantErr: true},
+		{name: "unsupported solver", opts: ACMEOptions{Email: "admin@example.com", Solver: "tls-alpn01"}, wantErr: true},
+		{name: "unsupported dns provider", opts: ACMEOptions{Email: "admin@example.com", Solver: SolverDNS01, DNSProvider: "route53", DNSToken: "token"}, wantErr: true},
+		{name: "missing dns token", opts: ACMEOptions{Email: "admin@example.com", Solver: SolverDNS01, DNSProvider: "cloudflare"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			err := opts.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(opts, tt.want) {
+				t.Fatalf("Validate() = %+v, want %+v", opts, tt.want)
+			}
+		})
+	}
+}
+
+func TestACMEOptionsDNSNames(t *testing.T) {
+	tests := []struct {
+		solver string
+		want   []string
+	}{
+		{SolverHTTP01, []string{"console.example.com", "s3.example.com"}},
+		{SolverDNS01, []string{"example.com", "*.example.com"}},
+	}
+	for _, tt := range tests {
+		opts := ACMEOptions{Solver: tt.solver}
+		if got := opts.DNSNames("example.com"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DNSNames(%s) = %v, want %v", tt.solver, got, tt.want)
+		}
+	}
+}
+
+func TestApplyCertificateAndSyncSecret(t *testing.T) {
+	ctx := context.Background()
+	sysNS := common.GetDefaultSystemNamespace(true)
+	legacy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata":   map[string]interface{}{"name": common.ACMETLSSecretName, "namespace": "default"},
+	}}
+	scheme := runtime.NewScheme()
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, map[schema.GroupVersionResource]string{
+		certificateGVR: "CertificateList",
+	}, legacy)
+	cs := fake.NewSimpleClientset()
+	client := &k8s.Client{Clientset: cs, DynamicClientset: dc}
+
+	if err := ApplyCertificate(ctx, client, []string{"example.com"}); err != nil {
+		t.Fatalf("ApplyCertificate() error = %v", err)
+	}
+	if _, err := dc.Resource(certificateGVR).Namespace(sysNS).Get(ctx, common.ACMETLSSecretName, metav1.GetOptions{}); err != nil {
+		t.Fatalf("certificate in %s not created: %v", sysNS, err)
+	}
+	if _, err := dc.Resource(certificateGVR).Namespace("default").Get(ctx, common.ACMETLSSecretName, metav1.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Fatalf("legacy certificate in default not deleted: %v", err)
+	}
+
+	if err := SyncACMESecret(ctx, client); err == nil {
+		t.Fatal("SyncACMESecret() should fail before the certificate is issued")
+	}
+	issued := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: common.ACMETLSSecretName, Namespace: sysNS},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("cert"), corev1.TLSPrivateKeyKey: []byte("key")},
+	}
+	if _, err := cs.CoreV1().Secrets(sysNS).Create(ctx, issued, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := SyncACMESecret(ctx, client); err != nil {
+			t.Fatalf("SyncACMESecret() error = %v", err)
+		}
+	}
+	got, err := cs.CoreV1().Secrets("default").Get(ctx, common.ACMETLSSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret not synced to default: %v", err)
+	}
+	if !reflect.DeepEqual(got.Data, issued.Data) || got.Type != corev1.SecretTypeTLS {
+		t.Fatalf("synced secret = %+v, want data %v", got, issued.Data)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package httptls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KeyPair pem encoded certificate and private key
+type KeyPair struct {
+	Cert []byte
+	Key  []byte
+	Leaf *x509.Certificate
+}
+
+// LoadKeyPair 读取证书及私钥, 并校验是否匹配
+func LoadKeyPair(certFile, keyFile string) (*KeyPair, error) {
+	cert, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read tls cert %s failed", certFile)
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read tls key %s failed", keyFile)
+	}
+	return ParseKeyPair(cert, key)
+}
+
+// ParseKeyPair 解析 pem 格式证书及私钥
+func ParseKeyPair(cert, key []byte) (*KeyPair, error) {
+	pair, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid tls cert or key")
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "parse tls cert failed")
+	}
+	return &KeyPair{Cert: cert, Key: key, Leaf: leaf}, nil
+}
+
+// ValidateDomain 校验证书是否覆盖 *.domain 且在有效期内
+func (kp *KeyPair) ValidateDomain(domain string) error {
+	if len(domain) == 0 {
+		return errors.New("domain is empty")
+	}
+	// 应用及控制台均使用 domain 的子域名
+	if err := kp.Leaf.VerifyHostname("console." + domain); err != nil {
+		return errors.Errorf("tls cert does not match *.%s, SANs: %s", domain, strings.Join(kp.Leaf.DNSNames, ","))
+	}
+	if err := kp.Leaf.VerifyHostname("quickon-check." + domain); err != nil {
+		return errors.Errorf("tls cert is not a wildcard cert of *.%s, SANs: %s", domain, strings.Join(kp.Leaf.DNSNames, ","))
+	}
+	now := time.Now()
+	if now.Before(kp.Leaf.NotBefore) {
+		return errors.Errorf("tls cert is not valid before %s", kp.Leaf.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(kp.Leaf.NotAfter) {
+		return errors.Errorf("tls cert expired at %s", kp.Leaf.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ApplySecret 在系统及默认命名空间创建或更新 tls secret
+func (kp *KeyPair) ApplySecret(ctx context.Context, client *k8s.Client, name string) error {
+	for _, ns := range []string{common.GetDefaultSystemNamespace(true), "default"} {
+		if err := kp.applySecret(ctx, client, ns, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applySecret 创建或更新单个命名空间的 tls secret, 内容未变化时跳过
+func (kp *KeyPair) applySecret(ctx context.Context, client *k8s.Client, ns, name string) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       kp.Cert,
+			corev1.TLSPrivateKeyKey: kp.Key,
+		},
+	}
+	old, err := client.GetSecret(ctx, ns, name, metav1.GetOptions{})
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return err
+		}
+		if _, err := client.CreateSecret(ctx, ns, secret, metav1.CreateOptions{}); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("create tls secret %s/%s failed", ns, name))
+		}
+		return nil
+	}
+	if old.Type != corev1.SecretTypeTLS {
+		// secret 类型不可变更, 需重建
+		if err := client.DeleteSecret(ctx, ns, name, metav1.DeleteOptions{}); err != nil {
+			return err
+		}
+		if _, err := client.CreateSecret(ctx, ns, secret, metav1.CreateOptions{}); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("create tls secret %s/%s failed", ns, name))
+		}
+		return nil
+	}
+	if bytes.Equal(old.Data[corev1.TLSCertKey], kp.Cert) && bytes.Equal(old.Data[corev1.TLSPrivateKeyKey], kp.Key) {
+		return nil
+	}
+	old.Data = secret.Data
+	if _, err := client.UpdateSecret(ctx, ns, old, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("update tls secret %s/%s failed", ns, name))
+	}
+	return nil
+}
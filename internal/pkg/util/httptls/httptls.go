@@ -25,6 +25,13 @@ func CheckReNewCertificate(force bool) (err error) {
 	log := log.GetInstance()
 	cfg, _ := config.LoadConfig()
 	domain := cfg.Domain
+	if cfg.TLS.Type == common.TLSTypeACME {
+		return checkACMECertificate(force)
+	}
+	if cfg.TLS.Type == common.TLSTypeSelfSigned {
+		log.Infof("domain %s uses offline self-signed cert, ca: %s", domain, CAFile())
+		return nil
+	}
 	if strings.HasSuffix(domain, "haogs.cn") || strings.HasSuffix(domain, "corp.cc") {
 		needRenew := false
 		if force {
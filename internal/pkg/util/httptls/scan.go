@@ -0,0 +1,106 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package httptls
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IngressCert ingress 引用的 tls secret 证书信息
+type IngressCert struct {
+	Namespace string    `json:"namespace" yaml:"namespace"`
+	Secret    string    `json:"secret" yaml:"secret"`
+	Domains   []string  `json:"domains,omitempty" yaml:"domains,omitempty"`
+	Issuer    string    `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	NotAfter  time.Time `json:"notAfter,omitempty" yaml:"notAfter,omitempty"`
+	Ingresses []string  `json:"ingresses" yaml:"ingresses"`
+	Error     string    `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// ExpiringWithin 证书在 d 时间内过期, 无法解析的证书也视为需要处理
+func (c IngressCert) ExpiringWithin(d time.Duration) bool {
+	if len(c.Error) > 0 {
+		return true
+	}
+	return c.NotAfter.Before(time.Now().Add(d))
+}
+
+// ScanIngressCerts 扫描所有命名空间 ingress 引用的 kubernetes.io/tls secret
+func ScanIngressCerts(ctx context.Context, client *k8s.Client) ([]IngressCert, error) {
+	ingresses, err := client.ListIngresses(ctx, metav1.NamespaceAll, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list ingress failed")
+	}
+	refs := map[string]*IngressCert{}
+	for _, ing := range ingresses.Items {
+		for _, t := range ing.Spec.TLS {
+			if len(t.SecretName) == 0 {
+				continue
+			}
+			key := fmt.Sprintf("%s/%s", ing.Namespace, t.SecretName)
+			ref, ok := refs[key]
+			if !ok {
+				ref = &IngressCert{Namespace: ing.Namespace, Secret: t.SecretName}
+				refs[key] = ref
+			}
+			if len(ref.Ingresses) == 0 || ref.Ingresses[len(ref.Ingresses)-1] != ing.Name {
+				ref.Ingresses = append(ref.Ingresses, ing.Name)
+			}
+		}
+	}
+	var certs []IngressCert
+	for _, ref := range refs {
+		secret, err := client.GetSecret(ctx, ref.Namespace, ref.Secret, metav1.GetOptions{})
+		switch {
+		case err != nil:
+			ref.Error = err.Error()
+		case secret.Type != corev1.SecretTypeTLS:
+			ref.Error = fmt.Sprintf("secret type is %s", secret.Type)
+		default:
+			leaf, err := parseLeaf(secret.Data[corev1.TLSCertKey])
+			if err != nil {
+				ref.Error = err.Error()
+				break
+			}
+			ref.Domains = leaf.DNSNames
+			if len(ref.Domains) == 0 && len(leaf.Subject.CommonName) > 0 {
+				ref.Domains = []string{leaf.Subject.CommonName}
+			}
+			ref.Issuer = leaf.Issuer.CommonName
+			if len(ref.Issuer) == 0 {
+				ref.Issuer = leaf.Issuer.String()
+			}
+			ref.NotAfter = leaf.NotAfter
+		}
+		certs = append(certs, *ref)
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		if certs[i].Namespace != certs[j].Namespace {
+			return certs[i].Namespace < certs[j].Namespace
+		}
+		return certs[i].Secret < certs[j].Secret
+	})
+	return certs, nil
+}
+
+func parseLeaf(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no pem certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
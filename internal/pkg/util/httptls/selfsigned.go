@@ -0,0 +1,152 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package httptls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/ergoapi/util/file"
+)
+
+const (
+	caValidity     = 10 * 365 * 24 * time.Hour
+	serverValidity = 825 * 24 * time.Hour
+)
+
+// CADir 离线自签 CA 存放目录
+func CADir() string {
+	return filepath.Join(common.GetDefaultDataDir(), "tls")
+}
+
+// CAFile 可导出的 CA 证书路径
+func CAFile() string {
+	return filepath.Join(CADir(), "ca.pem")
+}
+
+// LoadOrCreateCA 读取本地 CA, 不存在时生成, 私钥仅 root 可读
+func LoadOrCreateCA() (*KeyPair, error) {
+	certFile := CAFile()
+	keyFile := filepath.Join(CADir(), "ca-key.pem")
+	if file.CheckFileExists(certFile) && file.CheckFileExists(keyFile) {
+		return LoadKeyPair(certFile, keyFile)
+	}
+	ca, err := GenerateCA("Quickon Offline CA")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(CADir(), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, ca.Key, 0600); err != nil {
+		return nil, errors.Wrap(err, "save ca key failed")
+	}
+	if err := os.WriteFile(certFile, ca.Cert, 0644); err != nil {
+		return nil, errors.Wrap(err, "save ca cert failed")
+	}
+	return ca, nil
+}
+
+// GenerateCA 生成自签 CA
+func GenerateCA(cn string) (*KeyPair, error) {
+	tmpl := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: cn, Organization: []string{"Quickon"}},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	return sign(tmpl, nil, caValidity)
+}
+
+// IssueServer 使用 CA 签发 domain 及 *.domain 证书
+func (kp *KeyPair) IssueServer(domain string) (*KeyPair, error) {
+	if kp.Leaf == nil || !kp.Leaf.IsCA {
+		return nil, errors.New("issuer is not a ca")
+	}
+	tmpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "*." + domain, Organization: []string{"Quickon"}},
+		DNSNames:    []string{domain, "*." + domain},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	server, err := sign(tmpl, kp, serverValidity)
+	if err != nil {
+		return nil, err
+	}
+	// 附带 CA, 客户端可获取完整证书链
+	server.Cert = append(server.Cert, kp.Cert...)
+	return server, nil
+}
+
+func sign(tmpl *x509.Certificate, parent *KeyPair, validity time.Duration) (*KeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate key failed")
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	tmpl.SerialNumber = serial
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(validity)
+	issuer, signer := tmpl, interface{}(key)
+	if parent != nil {
+		issuer = parent.Leaf
+		pkey, err := parsePrivateKey(parent.Key)
+		if err != nil {
+			return nil, err
+		}
+		signer = pkey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "sign cert failed")
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	return ParseKeyPair(certPem, keyPem)
+}
+
+func parsePrivateKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem private key found")
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+// TrustInstructions 客户端信任离线 CA 的操作说明
+func TrustInstructions(caFile string) string {
+	return fmt.Sprintf(`import the quickon offline CA %s into your clients:
+  Linux (Debian/Ubuntu): cp %s /usr/local/share/ca-certificates/quickon-ca.crt && update-ca-certificates
+  Linux (RHEL/CentOS):   cp %s /etc/pki/ca-trust/source/anchors/quickon-ca.pem && update-ca-trust
+  macOS:                 sudo security add-trusted-cert -d -r trustRoot -k /Library/Keychains/System.keychain %s
+  Windows:               certutil -addstore -f ROOT %s
+  Firefox:               Settings -> Privacy & Security -> Certificates -> Import`, caFile, caFile, caFile, caFile, caFile)
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package httptls
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func TestIssueServer(t *testing.T) {
+	ca, err := GenerateCA("test ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ca.IssueServer("example.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.ValidateDomain("example.local"); err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	if _, err := server.Leaf.Verify(x509.VerifyOptions{DNSName: "console.example.local", Roots: pool}); err != nil {
+		t.Fatalf("verify by ca failed: %v", err)
+	}
+	if _, err := server.IssueServer("other.local"); err == nil {
+		t.Fatal("non ca cert should not issue")
+	}
+}
@@ -9,6 +9,7 @@ package initsystem
 import (
 	"context"
 	"os"
+	"strings"
 
 	"github.com/kardianos/service"
 )
@@ -26,14 +27,26 @@ type Config struct {
 }
 
 type DaemonService struct {
+	// Run 服务主逻辑, ctx 在服务停止时取消
+	Run    func(ctx context.Context) error
 	cancel context.CancelFunc
 }
 
 var nocontext = context.Background()
 
 func (es *DaemonService) Start(s service.Service) error {
-	_, cancel := context.WithCancel(nocontext)
+	ctx, cancel := context.WithCancel(nocontext)
 	es.cancel = cancel
+	if es.Run != nil {
+		go func() {
+			if err := es.Run(ctx); err != nil {
+				if logger, lerr := s.Logger(nil); lerr == nil {
+					logger.Error(err)
+				}
+				os.Exit(1)
+			}
+		}()
+	}
 	return nil
 }
 
@@ -44,3 +57,22 @@ func (es *DaemonService) Stop(s service.Service) error {
 	}
 	return nil
 }
+
+// NewService 创建系统服务, 支持前台运行及安装为 systemd 等服务
+func NewService(cfg *Config, ds *DaemonService) (service.Service, error) {
+	env := map[string]string{}
+	for _, e := range cfg.Env {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			env[k] = v
+		}
+	}
+	return service.New(ds, &service.Config{
+		Name:             cfg.Name,
+		DisplayName:      cfg.Name,
+		Description:      cfg.Desc,
+		WorkingDirectory: cfg.Dir,
+		Executable:       cfg.Exec,
+		Arguments:        cfg.Args,
+		EnvVars:          env,
+	})
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/easysoft/qcadmin/common"
 	"github.com/easysoft/qcadmin/internal/pkg/util/log/survey"
+	"github.com/easysoft/qcadmin/internal/pkg/util/redact"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"k8s.io/apimachinery/pkg/util/runtime"
@@ -29,6 +30,16 @@ type fileLogger struct {
 	logger *logrus.Logger
 }
 
+// redactFormatter 写入文件前脱敏, 不受 --show-secrets 影响
+type redactFormatter struct {
+	logrus.Formatter
+}
+
+func (r *redactFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	entry.Message = redact.String(entry.Message)
+	return r.Formatter.Format(entry)
+}
+
 // GetFileLogger returns a logger instance for the specified filename
 func GetFileLogger(filename string) Logger {
 	filedir := common.GetDefaultLogDir()
@@ -42,7 +53,7 @@ func GetFileLogger(filename string) Logger {
 		newLogger := &fileLogger{
 			logger: logrus.New(),
 		}
-		newLogger.logger.Formatter = &logrus.JSONFormatter{}
+		newLogger.logger.Formatter = &redactFormatter{Formatter: &logrus.JSONFormatter{}}
 		newLogger.logger.SetOutput(&lumberjack.Logger{
 			Filename:   fmt.Sprintf("%s/%s", filedir, filename),
 			MaxAge:     12,
@@ -193,7 +204,10 @@ func (f *fileLogger) GetLevel() logrus.Level {
 }
 
 func (f *fileLogger) Write(message []byte) (int, error) {
-	return f.logger.Out.Write(message)
+	if _, err := f.logger.Out.Write(redact.Bytes(message)); err != nil {
+		return 0, err
+	}
+	return len(message), nil
 }
 
 func (f *fileLogger) WriteString(message string) {
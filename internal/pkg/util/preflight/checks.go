@@ -850,3 +850,36 @@ func RunChecks(checks []Checker, ww io.Writer, ignorePreflightErrors bool) error
 	}
 	return nil
 }
+
+// CheckResult 单项检查结果
+type CheckResult struct {
+	Name  string `json:"name" yaml:"name"`
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// RunDiagnoseChecks 执行不依赖端口空闲等安装前状态的检查, 返回全部结果, 用于诊断
+func RunDiagnoseChecks() []CheckResult {
+	checks := []Checker{
+		IsPrivilegedUserCheck{},
+		NumCPUCheck{NumCPU: common.ControlPlaneNumCPU},
+		NumDiskCheck{NumDisk: common.ControlPlaneNumDisk},
+		MemCheck{Mem: common.ControlPlaneMem},
+		SwapCheck{},
+		SystemVerificationCheck{},
+		HostnameCheck{nodeName: zos.GetHostname()},
+	}
+	if runtime.GOOS == "linux" {
+		checks = append(checks,
+			FileContentCheck{Path: bridgenf, Content: []byte{'1'}},
+			FileContentCheck{Path: ipv4Forward, Content: []byte{'1'}})
+	}
+	var result []CheckResult
+	for _, c := range checks {
+		r := CheckResult{Name: c.Name()}
+		if err := c.Check(); err != nil {
+			r.Error = err.Error()
+		}
+		result = append(result, r)
+	}
+	return result
+}
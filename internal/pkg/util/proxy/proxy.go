@@ -0,0 +1,122 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/types"
+)
+
+// defaultNoProxy 本机及集群内部地址
+var defaultNoProxy = []string{"127.0.0.1", "localhost", ".svc", ".cluster.local", "kubeapi.k7s.local"}
+
+var envKeys = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"}
+
+// Flags 代理相关参数
+func Flags(p *config.Proxy) []types.Flag {
+	return []types.Flag{
+		{
+			Name:  "http-proxy",
+			P:     &p.HTTPProxy,
+			V:     p.HTTPProxy,
+			Usage: `http proxy, e.g: http://192.168.0.1:3128`,
+		},
+		{
+			Name:  "https-proxy",
+			P:     &p.HTTPSProxy,
+			V:     p.HTTPSProxy,
+			Usage: `https proxy, default same as http proxy`,
+		},
+		{
+			Name:  "no-proxy",
+			P:     &p.NoProxy,
+			V:     p.NoProxy,
+			Usage: `extra no proxy hosts, pod/service cidr and node ips are always included`,
+		},
+	}
+}
+
+// NoProxy 合并默认地址, 集群网段, 节点 ip 及用户配置
+func NoProxy(cfg *config.Config, ips ...string) string {
+	var all []string
+	all = append(all, defaultNoProxy...)
+	all = append(all, cfg.Cluster.PodCIDR, cfg.Cluster.ServiceCIDR)
+	all = append(all, cfg.GetIPs()...)
+	all = append(all, ips...)
+	if len(cfg.Domain) > 0 {
+		all = append(all, "."+cfg.Domain)
+	}
+	all = append(all, strings.Split(cfg.Proxy.NoProxy, ",")...)
+	seen := map[string]bool{}
+	var result []string
+	for _, s := range all {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 || seen[s] {
+			continue
+		}
+		seen[s] = true
+		result = append(result, s)
+	}
+	return strings.Join(result, ",")
+}
+
+// Env 代理环境变量, 未配置代理时返回 nil
+func Env(cfg *config.Config, ips ...string) map[string]string {
+	if !cfg.Proxy.Enabled() {
+		return nil
+	}
+	httpsProxy := cfg.Proxy.HTTPSProxy
+	if len(httpsProxy) == 0 {
+		httpsProxy = cfg.Proxy.HTTPProxy
+	}
+	env := map[string]string{
+		"HTTPS_PROXY": httpsProxy,
+		"NO_PROXY":    NoProxy(cfg, ips...),
+	}
+	if len(cfg.Proxy.HTTPProxy) > 0 {
+		env["HTTP_PROXY"] = cfg.Proxy.HTTPProxy
+	}
+	return env
+}
+
+// Setenv 导出代理环境变量供 req, helm 及下载使用, 需在发起请求前调用, 已存在的环境变量优先
+func Setenv(cfg *config.Config, ips ...string) {
+	for k, v := range Env(cfg, ips...) {
+		if len(os.Getenv(k)) == 0 && len(os.Getenv(strings.ToLower(k))) == 0 {
+			os.Setenv(k, v)
+		}
+	}
+}
+
+// EnvFile k3s.service.env 内容
+func EnvFile(cfg *config.Config, ips ...string) string {
+	var b strings.Builder
+	env := Env(cfg, ips...)
+	for _, k := range envKeys {
+		if v, ok := env[k]; ok {
+			fmt.Fprintf(&b, "%s=%s\n", k, v)
+		}
+	}
+	return b.String()
+}
+
+// HelmValues quickon chart 代理参数, 格式同 --set
+func HelmValues(cfg *config.Config) []string {
+	var values []string
+	env := Env(cfg)
+	for _, k := range envKeys {
+		if v, ok := env[k]; ok {
+			// --set 中逗号为分隔符
+			values = append(values, fmt.Sprintf("env.%s=%s", k, strings.ReplaceAll(v, ",", "\\,")))
+		}
+	}
+	return values
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/easysoft/qcadmin/internal/app/config"
+)
+
+func TestEnv(t *testing.T) {
+	cfg := &config.Config{Domain: "demo.local"}
+	if Env(cfg) != nil {
+		t.Fatal("want no env without proxy")
+	}
+	cfg.Proxy = config.Proxy{HTTPProxy: "http://10.0.0.1:3128", NoProxy: "git.example.com,10.0.0.2"}
+	cfg.Cluster.PodCIDR = "10.42.0.0/16"
+	cfg.Cluster.ServiceCIDR = "10.43.0.0/16"
+	cfg.Cluster.Master = []config.Node{{Host: "10.0.0.2"}}
+	env := Env(cfg, "10.0.0.3")
+	if env["HTTPS_PROXY"] != "http://10.0.0.1:3128" {
+		t.Fatalf("https proxy should default to http proxy, got %s", env["HTTPS_PROXY"])
+	}
+	want := "127.0.0.1,localhost,.svc,.cluster.local,kubeapi.k7s.local,10.42.0.0/16,10.43.0.0/16,10.0.0.2,10.0.0.3,.demo.local,git.example.com"
+	if env["NO_PROXY"] != want {
+		t.Fatalf("got %s, want %s", env["NO_PROXY"], want)
+	}
+	for _, v := range HelmValues(cfg) {
+		if strings.HasPrefix(v, "env.NO_PROXY=") && !strings.Contains(v, `\,`) {
+			t.Fatalf("comma not escaped: %s", v)
+		}
+	}
+}
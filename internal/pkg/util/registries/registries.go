@@ -0,0 +1,132 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package registries
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Path k3s 镜像仓库配置
+const Path = "/etc/rancher/k3s/registries.yaml"
+
+// Registries k3s registries.yaml
+type Registries struct {
+	Mirrors map[string]Mirror         `json:"mirrors,omitempty"`
+	Configs map[string]RegistryConfig `json:"configs,omitempty"`
+}
+
+type Mirror struct {
+	Endpoint []string `json:"endpoint"`
+}
+
+type RegistryConfig struct {
+	Auth *Auth `json:"auth,omitempty"`
+	TLS  *TLS  `json:"tls,omitempty"`
+}
+
+type Auth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+type TLS struct {
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// AddMirror 为 registry 追加 mirror 地址, 已存在的地址忽略
+func (r *Registries) AddMirror(registry string, endpoints ...string) {
+	if r.Mirrors == nil {
+		r.Mirrors = map[string]Mirror{}
+	}
+	m := r.Mirrors[registry]
+	for _, e := range endpoints {
+		exist := false
+		for _, old := range m.Endpoint {
+			if old == e {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			m.Endpoint = append(m.Endpoint, e)
+		}
+	}
+	r.Mirrors[registry] = m
+}
+
+// SetAuth 设置 registry 认证信息
+func (r *Registries) SetAuth(registry, username, password string) {
+	if r.Configs == nil {
+		r.Configs = map[string]RegistryConfig{}
+	}
+	c := r.Configs[registry]
+	c.Auth = &Auth{Username: username, Password: password}
+	r.Configs[registry] = c
+}
+
+// Merge 合并其他配置, mirror 地址追加, 认证信息覆盖
+func (r *Registries) Merge(o *Registries) {
+	if o == nil {
+		return
+	}
+	for name, m := range o.Mirrors {
+		r.AddMirror(name, m.Endpoint...)
+	}
+	for name, c := range o.Configs {
+		if r.Configs == nil {
+			r.Configs = map[string]RegistryConfig{}
+		}
+		r.Configs[name] = c
+	}
+}
+
+// Apply 解析命令行参数, mirror 格式 docker.io=https://mirror.example.com, auth 格式 registry.example.com=user:password
+func (r *Registries) Apply(mirrors, auths []string) error {
+	for _, m := range mirrors {
+		name, endpoints, ok := strings.Cut(m, "=")
+		if !ok || len(name) == 0 || len(endpoints) == 0 {
+			return errors.Errorf("invalid registry mirror %q, e.g: docker.io=https://mirror.example.com", m)
+		}
+		for _, e := range strings.Split(endpoints, ",") {
+			if !strings.HasPrefix(e, "http://") && !strings.HasPrefix(e, "https://") {
+				return errors.Errorf("invalid mirror endpoint %q, must start with http:// or https://", e)
+			}
+			r.AddMirror(name, e)
+		}
+	}
+	for _, a := range auths {
+		name, userpass, ok := strings.Cut(a, "=")
+		user, pass, ok2 := strings.Cut(userpass, ":")
+		if !ok || !ok2 || len(name) == 0 || len(user) == 0 {
+			return errors.Errorf("invalid registry auth for %q, e.g: registry.example.com=user:password", name)
+		}
+		r.SetAuth(name, user, pass)
+	}
+	return nil
+}
+
+// IsEmpty 未配置任何 mirror 或认证
+func (r *Registries) IsEmpty() bool {
+	return r == nil || len(r.Mirrors) == 0 && len(r.Configs) == 0
+}
+
+// Marshal 输出 yaml
+func (r *Registries) Marshal() ([]byte, error) {
+	return yaml.Marshal(r)
+}
+
+// Parse 解析 registries.yaml
+func Parse(data []byte) (*Registries, error) {
+	r := &Registries{}
+	if err := yaml.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package registries
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	r := &Registries{}
+	r.AddMirror("docker.io", "http://10.0.0.1:32378")
+	r.AddMirror("docker.io", "http://10.0.0.1:32378", "https://mirror.example.com")
+	r.SetAuth("mirror.example.com", "user", "pass")
+	data, err := r.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"http://10.0.0.1:32378", "https://mirror.example.com"}
+	if !reflect.DeepEqual(got.Mirrors["docker.io"].Endpoint, want) {
+		t.Fatalf("got %v, want %v", got.Mirrors["docker.io"].Endpoint, want)
+	}
+	if got.Configs["mirror.example.com"].Auth.Username != "user" {
+		t.Fatalf("auth not kept: %s", data)
+	}
+}
+
+func TestApply(t *testing.T) {
+	r := &Registries{}
+	if err := r.Apply([]string{"docker.io=https://a.example.com,https://b.example.com"}, []string{"a.example.com=user:p:ss"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Mirrors["docker.io"].Endpoint) != 2 {
+		t.Fatalf("got %v", r.Mirrors["docker.io"].Endpoint)
+	}
+	if r.Configs["a.example.com"].Auth.Password != "p:ss" {
+		t.Fatalf("got %v", r.Configs["a.example.com"].Auth)
+	}
+	for _, bad := range []string{"docker.io", "docker.io=mirror.example.com"} {
+		if err := r.Apply([]string{bad}, nil); err == nil {
+			t.Fatalf("want error for %s", bad)
+		}
+	}
+	if err := r.Apply(nil, []string{"a.example.com=user"}); err == nil {
+		t.Fatal("want error for auth without password")
+	}
+}
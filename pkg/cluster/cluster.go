@@ -28,6 +28,8 @@ import (
 	"github.com/easysoft/qcadmin/internal/pkg/types"
 	qcexec "github.com/easysoft/qcadmin/internal/pkg/util/exec"
 	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/easysoft/qcadmin/internal/pkg/util/proxy"
+	"github.com/easysoft/qcadmin/internal/pkg/util/registries"
 	"github.com/easysoft/qcadmin/internal/pkg/util/ssh"
 	"github.com/ergoapi/util/expass"
 	"github.com/ergoapi/util/exstr"
@@ -55,6 +57,9 @@ type Cluster struct {
 	ServiceCIDR           string
 	OffLine               bool
 	Registry              string
+	RegistryMirrors       []string
+	RegistryAuths         []string
+	Proxy                 config.Proxy
 	Storage               string
 	DataStore             string
 	IgnorePreflightErrors bool
@@ -84,7 +89,7 @@ func (c *Cluster) getInitFlags() []types.Flag {
 			Name:  "offline",
 			P:     &c.OffLine,
 			V:     c.OffLine,
-			Usage: `offline install, only whitelist users are supported`,
+			Usage: `offline install, stage the bundle by "q offline load" first`,
 		},
 		{
 			Name:   "hub",
@@ -120,6 +125,8 @@ func (c *Cluster) GetInitFlags() []types.Flag {
 	fs = append(fs, c.GetIPFlags()...)
 	fs = append(fs, c.getMasterFlags()...)
 	fs = append(fs, c.getInitFlags()...)
+	fs = append(fs, c.GetRegistryFlags()...)
+	fs = append(fs, proxy.Flags(&c.Proxy)...)
 	return fs
 }
 
@@ -268,6 +275,12 @@ func (c *Cluster) initMaster0(cfg *config.Config, sshClient ssh.Interface) error
 	if err := sshClient.Copy(cfg.Cluster.InitNode, master0tplSrc, master0tplDst); err != nil {
 		return errors.Errorf("copy master0 %s tpl failed, reason: %v", cfg.Cluster.InitNode, err)
 	}
+	if err := c.pushRegistries(cfg, cfg.Cluster.InitNode, sshClient); err != nil {
+		return err
+	}
+	if err := c.pushProxyEnv(cfg, cfg.Cluster.InitNode, sshClient); err != nil {
+		return err
+	}
 	if err := c.preinit(cfg.Cluster.InitNode, cfg.Cluster.InitNode, sshClient); err != nil {
 		return err
 	}
@@ -352,6 +365,12 @@ func (c *Cluster) joinNode(ip string, master bool, cfg *config.Config, sshClient
 	if err := sshClient.Copy(ip, tplSrc, tplDst); err != nil {
 		return errors.Errorf("%s copy tpl (%s:%s->%s:%s) failed, reason: %v", t, cfg.Cluster.InitNode, tplSrc, ip, tplDst, err)
 	}
+	if err := c.pushRegistries(cfg, ip, sshClient); err != nil {
+		return err
+	}
+	if err := c.pushProxyEnv(cfg, ip, sshClient); err != nil {
+		return err
+	}
 	if err := c.preinit(cfg.Cluster.InitNode, ip, sshClient); err != nil {
 		return err
 	}
@@ -382,8 +401,22 @@ func (c *Cluster) InitNode() error {
 	c.WorkerIPs = exstr.DuplicateStrElement(c.WorkerIPs)
 	otherMaster := c.MasterIPs[1:]
 	sshClient := ssh.NewSSHClient(&c.SSH, true)
+	// 重建配置前保留 q hub init 保存的 hub 地址, 读取失败时使用已解析的部分
+	old, err := config.LoadConfig()
+	if err != nil {
+		c.log.Debugf("load previous config failed, reason: %v", err)
+	}
 	cfg := config.LoadTruncateConfig()
+	cfg.Hub = old.Hub
 	cfg.Cluster.InitNode = c.MasterIPs[0]
+	cfg.Cluster.Registries = &registries.Registries{}
+	if err := cfg.Cluster.Registries.Apply(c.RegistryMirrors, c.RegistryAuths); err != nil {
+		return err
+	}
+	cfg.Proxy = c.Proxy
+	cfg.Cluster.PodCIDR = c.PodCIDR
+	cfg.Cluster.ServiceCIDR = c.ServiceCIDR
+	proxy.Setenv(cfg, c.MasterIPs...)
 	if err := c.initMaster0(cfg, sshClient); err != nil {
 		return err
 	}
@@ -428,7 +461,10 @@ func (c *Cluster) JoinNode() error {
 	c.MasterIPs = exstr.DuplicateStrElement(c.MasterIPs)
 	c.WorkerIPs = exstr.DuplicateStrElement(c.WorkerIPs)
 	sshClient := ssh.NewSSHClient(&c.SSH, true)
-	cfg, _ := config.LoadConfig()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
 	for _, host := range c.MasterIPs {
 		c.log.Debugf("ping master %s", host)
 		if err := sshClient.Ping(host); err != nil {
@@ -477,7 +513,10 @@ func (c *Cluster) deleteNode(ip string, sshClient ssh.Interface, kubeClient *k8s
 }
 
 func (c *Cluster) DeleteNode() error {
-	cfg, _ := config.LoadConfig()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
 	var wg sync.WaitGroup
 	sshClient := ssh.NewSSHClient(&cfg.Global.SSH, true)
 	kubeClient, err := k8s.NewSimpleClient(common.GetKubeConfig())
@@ -0,0 +1,129 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/gosuri/uitable"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const nodeRolePrefix = "node-role.kubernetes.io/"
+
+// Info 集群信息
+type Info struct {
+	Context     string     `json:"context" yaml:"context"`
+	Version     string     `json:"version" yaml:"version"`
+	InitNode    string     `json:"initNode,omitempty" yaml:"initNode,omitempty"`
+	CNI         string     `json:"cni,omitempty" yaml:"cni,omitempty"`
+	PodCIDR     string     `json:"podCIDR,omitempty" yaml:"podCIDR,omitempty"`
+	ServiceCIDR string     `json:"serviceCIDR,omitempty" yaml:"serviceCIDR,omitempty"`
+	Nodes       []NodeInfo `json:"nodes" yaml:"nodes"`
+}
+
+// NodeInfo 节点信息
+type NodeInfo struct {
+	Name             string   `json:"name" yaml:"name"`
+	IP               string   `json:"ip" yaml:"ip"`
+	Roles            []string `json:"roles" yaml:"roles"`
+	Ready            bool     `json:"ready" yaml:"ready"`
+	KubeletVersion   string   `json:"kubeletVersion" yaml:"kubeletVersion"`
+	OSImage          string   `json:"osImage" yaml:"osImage"`
+	KernelVersion    string   `json:"kernelVersion" yaml:"kernelVersion"`
+	ContainerRuntime string   `json:"containerRuntime" yaml:"containerRuntime"`
+	Arch             string   `json:"arch" yaml:"arch"`
+}
+
+// GetInfo 集群版本, 节点及网络配置
+func GetInfo(ctx context.Context) (*Info, error) {
+	client, err := k8s.NewSimpleClient(common.GetKubeConfig())
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{Context: common.CurrentContext(), Nodes: []NodeInfo{}}
+	if info.Version, err = client.GetGitVersion(ctx); err != nil {
+		return nil, err
+	}
+	if cfg, _ := config.LoadConfig(); cfg != nil {
+		info.InitNode = cfg.Cluster.InitNode
+		info.CNI = cfg.Cluster.CNI
+		info.PodCIDR = cfg.Cluster.PodCIDR
+		info.ServiceCIDR = cfg.Cluster.ServiceCIDR
+	}
+	nodes, err := client.ListNodes(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes.Items {
+		n := NodeInfo{
+			Name:             node.Name,
+			Roles:            []string{},
+			KubeletVersion:   node.Status.NodeInfo.KubeletVersion,
+			OSImage:          node.Status.NodeInfo.OSImage,
+			KernelVersion:    node.Status.NodeInfo.KernelVersion,
+			ContainerRuntime: node.Status.NodeInfo.ContainerRuntimeVersion,
+			Arch:             node.Status.NodeInfo.Architecture,
+		}
+		for _, addr := range node.Status.Addresses {
+			if addr.Type == corev1.NodeInternalIP {
+				n.IP = addr.Address
+				break
+			}
+		}
+		for label := range node.Labels {
+			if strings.HasPrefix(label, nodeRolePrefix) {
+				n.Roles = append(n.Roles, strings.TrimPrefix(label, nodeRolePrefix))
+			}
+		}
+		sort.Strings(n.Roles)
+		for _, cond := range node.Status.Conditions {
+			if cond.Type == corev1.NodeReady {
+				n.Ready = cond.Status == corev1.ConditionTrue
+			}
+		}
+		info.Nodes = append(info.Nodes, n)
+	}
+	sort.Slice(info.Nodes, func(i, j int) bool { return info.Nodes[i].Name < info.Nodes[j].Name })
+	return info, nil
+}
+
+func (i *Info) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.AddRow("CONTEXT:", i.Context)
+	table.AddRow("VERSION:", i.Version)
+	table.AddRow("INIT NODE:", i.InitNode)
+	table.AddRow("CNI:", i.CNI)
+	table.AddRow("POD CIDR:", i.PodCIDR)
+	table.AddRow("SERVICE CIDR:", i.ServiceCIDR)
+	table.AddRow("")
+	table.AddRow("NAME", "IP", "ROLES", "READY", "VERSION", "OS", "KERNEL", "RUNTIME")
+	for _, n := range i.Nodes {
+		roles := strings.Join(n.Roles, ",")
+		if len(roles) == 0 {
+			roles = "<none>"
+		}
+		table.AddRow(n.Name, n.IP, roles, n.Ready, n.KubeletVersion, n.OSImage, n.KernelVersion, n.ContainerRuntime)
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (i *Info) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, i)
+}
+
+func (i *Info) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, i)
+}
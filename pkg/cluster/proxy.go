@@ -0,0 +1,34 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/util/proxy"
+	"github.com/easysoft/qcadmin/internal/pkg/util/ssh"
+)
+
+// pushProxyEnv 在 k3s 启动前写入 k3s.service.env, containerd 拉取镜像同样使用该代理
+func (c *Cluster) pushProxyEnv(cfg *config.Config, ip string, sshClient ssh.Interface) error {
+	if !cfg.Proxy.Enabled() {
+		return nil
+	}
+	ips := append(append([]string{ip}, c.MasterIPs...), c.WorkerIPs...)
+	src := fmt.Sprintf("%s/k3s.service.env.%s", common.GetDefaultCacheDir(), ip)
+	if err := os.WriteFile(src, []byte(proxy.EnvFile(cfg, ips...)), common.FileMode0600); err != nil {
+		return err
+	}
+	if err := sshClient.Copy(ip, src, common.K3sServiceEnv); err != nil {
+		return errors.Errorf("copy k3s.service.env to %s failed, reason: %v", ip, err)
+	}
+	return nil
+}
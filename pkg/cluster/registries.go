@@ -0,0 +1,142 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	"github.com/easysoft/qcadmin/internal/pkg/types"
+	"github.com/easysoft/qcadmin/internal/pkg/util/registries"
+	"github.com/easysoft/qcadmin/internal/pkg/util/ssh"
+	"github.com/easysoft/qcadmin/pkg/hub"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func (c *Cluster) GetRegistryFlags() []types.Flag {
+	return []types.Flag{
+		{
+			Name:  "registry-mirror",
+			P:     &c.RegistryMirrors,
+			V:     c.RegistryMirrors,
+			Usage: `registry mirror, e.g: docker.io=https://mirror.example.com`,
+		},
+		{
+			Name:  "registry-auth",
+			P:     &c.RegistryAuths,
+			V:     c.RegistryAuths,
+			Usage: `registry auth, e.g: registry.example.com=user:password`,
+		},
+	}
+}
+
+// renderRegistries 生成 registries.yaml, 已部署 hub 时合并 hub mirror
+func renderRegistries(cfg *config.Config) ([]byte, error) {
+	r := cfg.Cluster.Registries
+	if len(cfg.Hub.Address) > 0 {
+		h := &hub.Hub{IP: cfg.Hub.Address}
+		r = h.Registries(cfg.Cluster.Registries)
+	}
+	if r.IsEmpty() {
+		return nil, nil
+	}
+	return r.Marshal()
+}
+
+// pushRegistries 在 k3s 启动前下发 registries.yaml
+func (c *Cluster) pushRegistries(cfg *config.Config, ip string, sshClient ssh.Interface) error {
+	data, err := renderRegistries(cfg)
+	if err != nil || data == nil {
+		return err
+	}
+	src := fmt.Sprintf("%s/registries.%s.yaml", common.GetDefaultCacheDir(), ip)
+	// 包含认证信息
+	if err := os.WriteFile(src, data, common.FileMode0600); err != nil {
+		return err
+	}
+	if err := sshClient.Copy(ip, src, registries.Path); err != nil {
+		return errors.Errorf("copy registries.yaml to %s failed, reason: %v", ip, err)
+	}
+	return nil
+}
+
+// SetRegistries 更新集群镜像仓库配置并逐个节点重启 k3s
+func (c *Cluster) SetRegistries(reset bool) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	ips := cfg.GetIPs()
+	if len(ips) == 0 {
+		return errors.New("no cluster node found, only support cluster created by q")
+	}
+	r := &registries.Registries{}
+	if !reset {
+		r.Merge(cfg.Cluster.Registries)
+	}
+	if err := r.Apply(c.RegistryMirrors, c.RegistryAuths); err != nil {
+		return err
+	}
+	cfg.Cluster.Registries = r
+	if err := cfg.SaveConfig(); err != nil {
+		return err
+	}
+	kubeClient, err := k8s.NewSimpleClient()
+	if err != nil {
+		return errors.Wrap(err, "load k8s client failed")
+	}
+	sshClient := ssh.NewSSHClient(&cfg.Global.SSH, true)
+	for i, ip := range ips {
+		c.log.Infof("[%d/%d] update registries on %s", i+1, len(ips), ip)
+		if r.IsEmpty() && len(cfg.Hub.Address) == 0 {
+			if err := sshClient.CmdAsync(ip, "rm -f "+registries.Path); err != nil {
+				return errors.Errorf("remove registries.yaml on %s failed, reason: %v", ip, err)
+			}
+		} else if err := c.pushRegistries(cfg, ip, sshClient); err != nil {
+			return err
+		}
+		if err := sshClient.CmdAsync(ip, "systemctl restart k3s"); err != nil {
+			return errors.Errorf("restart k3s on %s failed, reason: %v", ip, err)
+		}
+		if err := c.waitNodeReady(kubeClient, ip, 5*time.Minute); err != nil {
+			return err
+		}
+		c.log.Donef("node %s is ready", ip)
+	}
+	return nil
+}
+
+// waitNodeReady 等待节点重新 Ready, 重启期间 apiserver 可能不可用
+func (c *Cluster) waitNodeReady(kubeClient *k8s.Client, ip string, timeout time.Duration) error {
+	c.log.StartWait(fmt.Sprintf("waiting node %s ready", ip))
+	defer c.log.StopWait()
+	// 等待 kubelet 上报新状态
+	time.Sleep(10 * time.Second)
+	err := wait.PollImmediate(5*time.Second, timeout, func() (bool, error) {
+		node, err := kubeClient.GetNodeByIP(context.TODO(), ip)
+		if err != nil {
+			return false, nil
+		}
+		for _, cond := range node.Status.Conditions {
+			if cond.Type == corev1.NodeReady {
+				return cond.Status == corev1.ConditionTrue, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return errors.Errorf("node %s not ready after k3s restart, reason: %v", ip, err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/util/registries"
+)
+
+func TestRenderRegistriesWithHub(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Hub.Address = "192.168.1.2"
+	cfg.Cluster.Registries = &registries.Registries{}
+	if err := cfg.Cluster.Registries.Apply([]string{"quay.io=https://mirror.example.com"}, []string{"registry.example.com=admin:pass"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := renderRegistries(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"http://192.168.1.2:", "https://mirror.example.com", "registry.example.com", "password: pass"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("registries.yaml missing %q:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+// Package contexts 管理多集群上下文, 每个上下文包含独立的配置, 凭据及 kubeconfig
+package contexts
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/ergoapi/util/file"
+	"github.com/gosuri/uitable"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var nameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Context 上下文信息
+type Context struct {
+	Name       string `json:"name" yaml:"name"`
+	Current    bool   `json:"current" yaml:"current"`
+	Domain     string `json:"domain,omitempty" yaml:"domain,omitempty"`
+	Server     string `json:"server,omitempty" yaml:"server,omitempty"`
+	KubeConfig string `json:"kubeconfig" yaml:"kubeconfig"`
+}
+
+type ContextList []Context
+
+func (l ContextList) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.AddRow("CURRENT", "NAME", "DOMAIN", "SERVER", "KUBECONFIG")
+	for _, c := range l {
+		current := ""
+		if c.Current {
+			current = "*"
+		}
+		table.AddRow(current, c.Name, c.Domain, c.Server, c.KubeConfig)
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (l ContextList) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, l)
+}
+
+func (l ContextList) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, l)
+}
+
+// ValidateName 上下文名称仅支持小写字母, 数字及 -
+func ValidateName(name string) error {
+	if !nameRegexp.MatchString(name) {
+		return errors.Errorf("invalid context name %q, must consist of lower case alphanumeric characters or '-'", name)
+	}
+	return nil
+}
+
+// Exists default 始终存在, 其他上下文以目录判断, 非法名称视为不存在
+func Exists(name string) bool {
+	if name == common.DefaultContextName {
+		return true
+	}
+	return ValidateName(name) == nil && file.CheckFileExists(common.GetContextDir(name))
+}
+
+// Names 全部上下文名称, default 在前
+func Names() []string {
+	names := []string{common.DefaultContextName}
+	entries, err := os.ReadDir(filepath.Join(common.GetContextDir(common.DefaultContextName), common.ContextsDir))
+	if err != nil {
+		return names
+	}
+	var others []string
+	for _, e := range entries {
+		if e.IsDir() && ValidateName(e.Name()) == nil && e.Name() != common.DefaultContextName {
+			others = append(others, e.Name())
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// With 临时切换到指定上下文执行 fn, 完成后恢复
+func With(name string, fn func() error) error {
+	if !Exists(name) {
+		return errors.Errorf("context %s not found", name)
+	}
+	prev := common.CurrentContext()
+	common.SetContext(name)
+	defer common.SetContext(prev)
+	return fn()
+}
+
+// List 上下文列表
+func List() (ContextList, error) {
+	current := common.CurrentContext()
+	var list ContextList
+	for _, name := range Names() {
+		c := Context{Name: name, Current: name == current}
+		err := With(name, func() error {
+			c.KubeConfig = common.GetKubeConfig()
+			c.Server = kubeServer(c.KubeConfig)
+			cfg, err := config.LoadConfig()
+			c.Domain = cfg.Domain
+			return err
+		})
+		if err != nil {
+			c.Domain = fmt.Sprintf("<%v>", err)
+		}
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+// kubeServer kubeconfig 当前上下文的 apiserver 地址
+func kubeServer(kubeconfig string) string {
+	if !file.CheckFileExists(kubeconfig) {
+		return ""
+	}
+	kcfg, err := clientcmd.LoadFromFile(kubeconfig)
+	if err != nil {
+		return ""
+	}
+	kctx, ok := kcfg.Contexts[kcfg.CurrentContext]
+	if !ok {
+		return ""
+	}
+	if cluster, ok := kcfg.Clusters[kctx.Cluster]; ok {
+		return cluster.Server
+	}
+	return ""
+}
+
+// Use 切换当前上下文
+func Use(name string) error {
+	if !Exists(name) {
+		return errors.Errorf("context %s not found", name)
+	}
+	return os.WriteFile(common.GetCurrentContextFile(), []byte(name+"\n"), common.FileMode0600)
+}
+
+// Remove 删除上下文目录, 删除当前上下文时切换到 default
+func Remove(name string) error {
+	if name == common.DefaultContextName {
+		return errors.New("default context can not be removed")
+	}
+	if !Exists(name) {
+		return errors.Errorf("context %s not found", name)
+	}
+	if err := os.RemoveAll(common.GetContextDir(name)); err != nil {
+		return err
+	}
+	if b, err := os.ReadFile(common.GetCurrentContextFile()); err == nil && string(b) == name+"\n" {
+		return Use(common.DefaultContextName)
+	}
+	return nil
+}
+
+// AddOption q context add 参数
+type AddOption struct {
+	Name       string
+	KubeConfig string
+	Config     string
+	Domain     string
+	APIToken   string
+}
+
+func (o *AddOption) Validate() error {
+	if err := ValidateName(o.Name); err != nil {
+		return err
+	}
+	if Exists(o.Name) {
+		return errors.Errorf("context %s already exists", o.Name)
+	}
+	if len(o.KubeConfig) == 0 {
+		return errors.New("--kubeconfig is required")
+	}
+	if _, err := clientcmd.LoadFromFile(o.KubeConfig); err != nil {
+		return errors.Wrapf(err, "invalid kubeconfig %s", o.KubeConfig)
+	}
+	if len(o.Config) > 0 && !file.CheckFileExists(o.Config) {
+		return errors.Errorf("import config %s not found", o.Config)
+	}
+	return nil
+}
+
+// Run 创建上下文目录, 复制 kubeconfig, 导入或生成配置, 失败时清理
+func (o *AddOption) Run() (err error) {
+	dir := common.GetContextDir(o.Name)
+	if err := os.MkdirAll(dir+"/.kube", common.FileMode0700); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+	return With(o.Name, func() error {
+		kubeconfig, err := os.ReadFile(o.KubeConfig)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(common.DefaultQuickONKubeConfig(), kubeconfig, common.FileMode0600); err != nil {
+			return err
+		}
+		cfg := config.NewConfig()
+		if len(o.Config) > 0 {
+			b, err := os.ReadFile(o.Config)
+			if err != nil {
+				return err
+			}
+			if cfg, err = config.ApplyConfig(b); err != nil {
+				return errors.Wrapf(err, "import config %s failed", o.Config)
+			}
+		}
+		if len(o.Domain) > 0 {
+			cfg.Domain = o.Domain
+		}
+		if len(o.APIToken) > 0 {
+			cfg.APIToken = o.APIToken
+		}
+		return cfg.SaveConfig()
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package contexts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/status"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/ergoapi/util/color"
+	"github.com/ergoapi/util/file"
+	"github.com/gosuri/uitable"
+)
+
+// ContextStatus 单个上下文的状态摘要
+type ContextStatus struct {
+	Context  string   `json:"context" yaml:"context"`
+	Current  bool     `json:"current" yaml:"current"`
+	Domain   string   `json:"domain,omitempty" yaml:"domain,omitempty"`
+	Version  string   `json:"version,omitempty" yaml:"version,omitempty"`
+	Nodes    string   `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+	Healthy  bool     `json:"healthy" yaml:"healthy"`
+	Failures []string `json:"failures,omitempty" yaml:"failures,omitempty"`
+	Error    string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type ContextStatusList []ContextStatus
+
+func (l ContextStatusList) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.MaxColWidth = 80
+	table.AddRow("CONTEXT", "DOMAIN", "VERSION", "NODES", "STATUS", "MESSAGE")
+	for _, s := range l {
+		name := s.Context
+		if s.Current {
+			name += "*"
+		}
+		health, message := color.SGreen("health"), ""
+		if !s.Healthy {
+			health = color.SRed("unhealth")
+			message = strings.Join(s.Failures, "; ")
+		}
+		if len(s.Error) > 0 {
+			message = s.Error
+		}
+		table.AddRow(name, s.Domain, s.Version, s.Nodes, health, message)
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (l ContextStatusList) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, l)
+}
+
+func (l ContextStatusList) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, l)
+}
+
+// Status 依次采集全部上下文的状态, 单个上下文失败不影响其他上下文
+func Status(ctx context.Context, option status.K8sStatusOption) ContextStatusList {
+	current := common.CurrentContext()
+	var list ContextStatusList
+	for _, name := range Names() {
+		s := ContextStatus{Context: name, Current: name == current}
+		err := With(name, func() error {
+			if cfg, _ := config.LoadConfig(); cfg != nil {
+				s.Domain = cfg.Domain
+			}
+			opt := option
+			opt.KubeConfig = common.GetKubeConfig()
+			opt.Wait = false
+			if !file.CheckFileExists(opt.KubeConfig) {
+				return errors.Errorf("kubeconfig %s not found", opt.KubeConfig)
+			}
+			collector, err := status.NewK8sStatusCollector(opt)
+			if err != nil {
+				return err
+			}
+			result, err := collector.Status(ctx)
+			if result != nil {
+				s.Version = result.KubeStatus.Version
+				s.Nodes = fmt.Sprintf("%d/%d", result.KubeStatus.NodeCount["ready"], result.KubeStatus.NodeCount["total"])
+				s.Failures = result.Failures
+			}
+			return err
+		})
+		if err != nil {
+			s.Error = err.Error()
+		}
+		s.Healthy = len(s.Error) == 0 && len(s.Failures) == 0
+		list = append(list, s)
+	}
+	return list
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	"github.com/easysoft/qcadmin/internal/pkg/plugin"
+	"github.com/easysoft/qcadmin/internal/pkg/status"
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/httptls"
+	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ServiceName 安装为系统服务时的名称
+const ServiceName = "qcadmin-daemon"
+
+type Option struct {
+	Listen     string
+	Interval   time.Duration
+	KubeConfig string
+	log        log.Logger
+	client     *k8s.Client
+	collector  *status.K8sStatusCollector
+	// metrics 最近一次完成采集的快照, 采集在新快照上进行, 完成后整体替换
+	metrics atomic.Pointer[metrics]
+}
+
+func New(f factory.Factory) *Option {
+	return &Option{
+		Listen:   fmt.Sprintf(":%d", common.DefaultDaemonPort),
+		Interval: time.Minute,
+		log:      f.GetLog(),
+	}
+}
+
+// Run 定时采集状态并通过 /metrics 暴露, ctx 取消时退出
+func (o *Option) Run(ctx context.Context) error {
+	if len(o.KubeConfig) == 0 {
+		o.KubeConfig = common.GetKubeConfig()
+	}
+	client, err := k8s.NewClient("", o.KubeConfig)
+	if err != nil {
+		return errors.Wrap(err, "create k8s client failed")
+	}
+	o.client = client
+	o.collector, err = status.NewK8sStatusCollector(status.K8sStatusOption{KubeConfig: o.KubeConfig, Log: o.log})
+	if err != nil {
+		return errors.Wrap(err, "create status collector failed")
+	}
+	o.metrics.Store(newMetrics())
+	go o.loop(ctx)
+
+	gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return o.metrics.Load().registry.Gather()
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	srv := &http.Server{Addr: o.Listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	o.log.Infof("serving metrics on %s/metrics, collect every %s", o.Listen, o.Interval)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (o *Option) loop(ctx context.Context) {
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+	for {
+		o.collect(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect 单次采集, 各项互不影响, 失败时仅记录日志.
+// 采集耗时较长, 在新快照上采集完成后再替换, 避免 /metrics 读到部分指标
+func (o *Option) collect(ctx context.Context) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, o.Interval)
+	defer cancel()
+	m := newMetrics()
+	o.collectStatus(ctx, m)
+	o.collectNodes(m)
+	o.syncACMESecret(ctx)
+	o.collectCerts(ctx, m)
+	o.collectBackup(m)
+	m.lastCollect.Set(float64(time.Now().Unix()))
+	m.collectSeconds.Set(time.Since(start).Seconds())
+	o.metrics.Store(m)
+}
+
+func (o *Option) collectStatus(ctx context.Context, m *metrics) {
+	s, err := o.collector.Status(ctx)
+	if err != nil || s == nil {
+		o.log.Warnf("collect status failed: %v", err)
+		m.ready.Set(0)
+		return
+	}
+	m.ready.Set(boolValue(len(s.Failures) == 0))
+	for state, count := range s.KubeStatus.NodeCount {
+		m.nodes.WithLabelValues(state).Set(float64(count))
+	}
+	for scope, states := range map[string]status.PodStateMap{"k8s": s.KubeStatus.PodState, "qucheng": s.QStatus.PodState} {
+		for name, state := range states {
+			if state.Disabled {
+				continue
+			}
+			m.replicas.WithLabelValues(scope, name, state.Type, state.Release, "desired").Set(float64(state.Desired))
+			m.replicas.WithLabelValues(scope, name, state.Type, state.Release, "ready").Set(float64(state.Ready))
+			m.replicas.WithLabelValues(scope, name, state.Type, state.Release, "available").Set(float64(state.Available))
+			m.restarts.WithLabelValues(scope, name, state.Release).Set(float64(state.Restarts))
+			m.warnings.WithLabelValues(scope, name, state.Release).Set(float64(len(state.Warnings)))
+		}
+	}
+	for name, state := range s.QStatus.PluginState {
+		for _, health := range []string{plugin.HealthHealthy, plugin.HealthUnhealthy, plugin.HealthUnknown, plugin.HealthDisabled} {
+			m.plugin.WithLabelValues(name, health).Set(boolValue(state.Health == health))
+		}
+	}
+	m.console.Set(boolValue(s.QStatus.Console != nil && s.QStatus.Console.Ready))
+}
+
+// collectNodes 依赖 metrics-server
+func (o *Option) collectNodes(m *metrics) {
+	resources, err := o.client.GetNodeResources("", labels.Everything())
+	if err != nil {
+		o.log.Warnf("collect node resources failed: %v", err)
+		return
+	}
+	for _, r := range resources {
+		for t, v := range map[string]string{"usage": r.CPUUsages, "requests": r.CPURequests, "limits": r.CPULimits, "capacity": r.CPUCapacity} {
+			if q, err := resource.ParseQuantity(v); err == nil {
+				m.nodeCPU.WithLabelValues(r.NodeName, r.NodeIP, t).Set(float64(q.MilliValue()) / 1000)
+			}
+		}
+		for t, v := range map[string]string{"usage": r.MemoryUsages, "requests": r.MemoryRequests, "limits": r.MemoryLimits, "capacity": r.MemoryCapacity} {
+			if q, err := resource.ParseQuantity(v); err == nil {
+				m.nodeMemory.WithLabelValues(r.NodeName, r.NodeIP, t).Set(float64(q.Value()))
+			}
+		}
+		m.nodePods.WithLabelValues(r.NodeName, r.NodeIP, "allocated").Set(float64(r.AllocatedPods))
+		m.nodePods.WithLabelValues(r.NodeName, r.NodeIP, "capacity").Set(float64(r.PodCapacity))
+	}
+}
+
+func (o *Option) collectCerts(ctx context.Context, m *metrics) {
+	certs, err := httptls.ScanIngressCerts(ctx, o.client)
+	if err != nil {
+		o.log.Warnf("collect certs failed: %v", err)
+		return
+	}
+	for _, c := range certs {
+		if len(c.Error) > 0 {
+			o.log.Debugf("skip cert %s/%s: %s", c.Namespace, c.Secret, c.Error)
+			continue
+		}
+		m.certExpiry.WithLabelValues(c.Namespace, c.Secret, c.Issuer).Set(float64(c.NotAfter.Unix()))
+	}
+}
+
+// syncACMESecret acme 证书仅在系统命名空间签发, 续期后同步到 default 命名空间
+func (o *Option) syncACMESecret(ctx context.Context) {
+	cfg, _ := config.LoadConfig()
+	if cfg == nil || cfg.TLS.Type != common.TLSTypeACME {
+		return
+	}
+	if err := httptls.SyncACMESecret(ctx, o.client); err != nil {
+		o.log.Debugf("sync acme tls secret failed: %v", err)
+	}
+}
+
+// collectBackup 本机 etcd 快照, 使用外部数据库时无快照
+func (o *Option) collectBackup(m *metrics) {
+	cfg, _ := config.LoadConfig()
+	dataDir := ""
+	if cfg != nil {
+		dataDir = cfg.DataDir
+	}
+	dir := filepath.Join(common.GetDefaultQuickonPlatformDir(dataDir), "server", "db", "snapshots")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		o.log.Debugf("read snapshot dir %s failed: %v", dir, err)
+		return
+	}
+	var latest time.Time
+	count := 0
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() {
+			continue
+		}
+		count++
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	m.backupCount.Set(float64(count))
+	if count > 0 {
+		m.backupLast.Set(float64(latest.Unix()))
+	}
+}
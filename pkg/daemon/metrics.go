@@ -0,0 +1,113 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package daemon
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "qcadmin"
+
+// metrics 单轮采集结果, 每轮使用新的快照, 避免保留已删除对象的指标
+type metrics struct {
+	registry *prometheus.Registry
+
+	lastCollect    prometheus.Gauge
+	collectSeconds prometheus.Gauge
+	ready          prometheus.Gauge
+	nodes          *prometheus.GaugeVec
+	replicas       *prometheus.GaugeVec
+	restarts       *prometheus.GaugeVec
+	warnings       *prometheus.GaugeVec
+	plugin         *prometheus.GaugeVec
+	console        prometheus.Gauge
+
+	nodeCPU    *prometheus.GaugeVec
+	nodeMemory *prometheus.GaugeVec
+	nodePods   *prometheus.GaugeVec
+
+	certExpiry *prometheus.GaugeVec
+
+	backupLast  prometheus.Gauge
+	backupCount prometheus.Gauge
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		lastCollect: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "last_collect_timestamp_seconds",
+			Help: "Unix time of the last collection.",
+		}),
+		collectSeconds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "collect_duration_seconds",
+			Help: "Duration of the last collection.",
+		}),
+		ready: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "status_ready",
+			Help: "Whether nodes, components, plugins and console are all ready (1) or not (0).",
+		}),
+		nodes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "cluster_nodes",
+			Help: "Number of cluster nodes by state.",
+		}, []string{"state"}),
+		replicas: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "component_replicas",
+			Help: "Replicas of components by state.",
+		}, []string{"scope", "component", "kind", "release", "state"}),
+		restarts: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "component_restarts",
+			Help: "Total container restarts of component pods.",
+		}, []string{"scope", "component", "release"}),
+		warnings: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "component_warning_events",
+			Help: "Number of recent warning events of components.",
+		}, []string{"scope", "component", "release"}),
+		plugin: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "plugin_health",
+			Help: "Plugin health, 1 for the current health label.",
+		}, []string{"plugin", "health"}),
+		console: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "console_up",
+			Help: "Whether the quickon console responds (1) or not (0).",
+		}),
+		nodeCPU: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "node_cpu_cores",
+			Help: "Node cpu cores by type.",
+		}, []string{"node", "ip", "type"}),
+		nodeMemory: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "node_memory_bytes",
+			Help: "Node memory bytes by type.",
+		}, []string{"node", "ip", "type"}),
+		nodePods: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "node_pods",
+			Help: "Node pods by type.",
+		}, []string{"node", "ip", "type"}),
+		certExpiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "cert_expiry_timestamp_seconds",
+			Help: "Unix time when the tls certificate referenced by ingress expires.",
+		}, []string{"namespace", "secret", "issuer"}),
+		backupLast: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "backup_last_timestamp_seconds",
+			Help: "Unix time of the latest etcd snapshot.",
+		}),
+		backupCount: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "backup_snapshots",
+			Help: "Number of etcd snapshots.",
+		}),
+	}
+	m.registry.MustRegister(m.lastCollect, m.collectSeconds, m.ready, m.nodes, m.replicas, m.restarts, m.warnings,
+		m.plugin, m.console, m.nodeCPU, m.nodeMemory, m.nodePods, m.certExpiry, m.backupLast, m.backupCount)
+	return m
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package diagnose
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path"
+	"time"
+
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/util/redact"
+)
+
+// IndexEntry 归档内文件说明, 采集失败时记录错误
+type IndexEntry struct {
+	Path        string `json:"path"`
+	Description string `json:"description"`
+	Size        int    `json:"size"`
+	Error       string `json:"error,omitempty"`
+}
+
+// archive 诊断包, 文件统一放在 prefix 目录下, 最后写入 index.json
+type archive struct {
+	prefix string
+	f      *os.File
+	gz     *gzip.Writer
+	tw     *tar.Writer
+	now    time.Time
+	index  []IndexEntry
+}
+
+func newArchive(dst, prefix string) (*archive, error) {
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, common.FileMode0600)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	return &archive{prefix: prefix, f: f, gz: gz, tw: tar.NewWriter(gz), now: time.Now()}, nil
+}
+
+// add 写入文件并登记索引, err 不为空且无内容时仅登记
+func (a *archive) add(name, desc string, data []byte, err error) error {
+	entry := IndexEntry{Path: name, Description: desc, Size: len(data)}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	a.index = append(a.index, entry)
+	if len(data) == 0 && err != nil {
+		return nil
+	}
+	if !redact.ShowSecrets() {
+		data = redact.Bytes(data)
+	}
+	return a.write(name, data)
+}
+
+func (a *archive) write(name string, data []byte) error {
+	if err := a.tw.WriteHeader(&tar.Header{
+		Name:    path.Join(a.prefix, name),
+		Mode:    common.FileMode0644,
+		Size:    int64(len(data)),
+		ModTime: a.now,
+	}); err != nil {
+		return err
+	}
+	_, err := a.tw.Write(data)
+	return err
+}
+
+func (a *archive) close() error {
+	index, err := json.MarshalIndent(a.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := a.write("index.json", index); err != nil {
+		return err
+	}
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	if err := a.gz.Close(); err != nil {
+		return err
+	}
+	return a.f.Close()
+}
@@ -0,0 +1,383 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package diagnose
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	"github.com/easysoft/qcadmin/internal/pkg/status"
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
+	"github.com/easysoft/qcadmin/internal/pkg/util/hostinfo"
+	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/easysoft/qcadmin/internal/pkg/util/preflight"
+	"github.com/easysoft/qcadmin/internal/pkg/util/ssh"
+	"github.com/gosuri/uitable"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// apiTimeout 单次 k8s 请求超时
+	apiTimeout = 30 * time.Second
+	// nodeTimeout 单条节点命令超时
+	nodeTimeout = time.Minute
+	// maxLogBytes 单个容器及本地日志文件截取大小
+	maxLogBytes = 512 * 1024
+	// maxLogLines 单个容器日志截取行数, 取最新的日志
+	maxLogLines = 5000
+)
+
+// nodeCommands 通过 ssh 在每个节点执行的命令
+var nodeCommands = []struct {
+	name string
+	desc string
+	cmd  string
+}{
+	{"system.txt", "os, uptime, disk and memory", "uname -a; cat /etc/os-release; uptime; df -h; free -m"},
+	{"network.txt", "ip address and route", "ip addr; ip route"},
+	{"k3s-service.txt", "k3s systemd unit and status", "systemctl cat k3s --no-pager; systemctl status k3s --no-pager -l"},
+	{"k3s-journal.log", "k3s journal", "journalctl -u k3s --no-pager -n 5000"},
+}
+
+type Option struct {
+	// Dest 诊断包路径, 默认 /tmp/qdiagnose-<timestamp>.tar.gz
+	Dest string
+	// Since 容器日志及事件的时间范围
+	Since time.Duration
+	// SkipNodes 不通过 ssh 采集节点信息
+	SkipNodes bool
+	log       log.Logger
+	archive   *archive
+}
+
+func New(f factory.Factory) *Option {
+	return &Option{
+		Since: 6 * time.Hour,
+		log:   f.GetLog(),
+	}
+}
+
+// Run 采集诊断信息并打包, 单项失败记录在 index.json 中, 不中断采集
+func (o *Option) Run() (string, error) {
+	prefix := fmt.Sprintf("qdiagnose-%d", time.Now().Unix())
+	if len(o.Dest) == 0 {
+		o.Dest = filepath.Join(os.TempDir(), prefix+".tar.gz")
+	}
+	a, err := newArchive(o.Dest, prefix)
+	if err != nil {
+		return "", errors.Wrap(err, "create archive failed")
+	}
+	o.archive = a
+	cfg, _ := config.LoadConfig()
+	steps := []struct {
+		name string
+		fn   func(cfg *config.Config) error
+	}{
+		{"host", o.collectHost},
+		{"quickon", o.collectQuickon},
+		{"kubernetes", o.collectKube},
+		{"helm", o.collectHelm},
+		{"nodes", o.collectNodes},
+	}
+	for _, s := range steps {
+		o.log.StartWait(fmt.Sprintf("collect %s info", s.name))
+		err := s.fn(cfg)
+		o.log.StopWait()
+		if err != nil {
+			a.close()
+			return "", errors.Wrapf(err, "collect %s info failed", s.name)
+		}
+		o.log.Donef("collect %s info done", s.name)
+	}
+	if err := a.close(); err != nil {
+		return "", errors.Wrap(err, "write archive failed")
+	}
+	return o.Dest, nil
+}
+
+func (o *Option) addJSON(name, desc string, v interface{}, err error) error {
+	var data []byte
+	if err == nil {
+		data, err = json.MarshalIndent(v, "", "  ")
+	}
+	return o.archive.add(name, desc, data, err)
+}
+
+func (o *Option) collectHost(cfg *config.Config) error {
+	if err := o.addJSON("host/hostinfo.json", "host info", hostinfo.New(), nil); err != nil {
+		return err
+	}
+	return o.addJSON("host/preflight.json", "preflight check results", preflight.RunDiagnoseChecks(), nil)
+}
+
+func (o *Option) collectQuickon(cfg *config.Config) error {
+	redacted, err := cfg.Redacted()
+	var data []byte
+	if err == nil {
+		data, err = yaml.Marshal(redacted)
+	}
+	if err := o.archive.add("quickon/config.yaml", "q config", data, err); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	collector, err := status.NewK8sStatusCollector(status.K8sStatusOption{KubeConfig: common.GetKubeConfig(), Log: o.log, WaitDuration: apiTimeout})
+	var s *status.Status
+	if err == nil {
+		s, err = collector.Status(ctx)
+	}
+	if err := o.addJSON("quickon/status.json", "q status", s, err); err != nil {
+		return err
+	}
+	// 本地日志
+	logDir := common.GetDefaultLogDir()
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		return o.archive.add("quickon/logs", "q logs", nil, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		data, err := tailFile(filepath.Join(logDir, e.Name()), maxLogBytes)
+		if err := o.archive.add("quickon/logs/"+e.Name(), "q log, tail", data, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Option) collectKube(cfg *config.Config) error {
+	client, err := k8s.NewSimpleClient()
+	if err != nil {
+		return o.archive.add("k8s", "kubernetes", nil, err)
+	}
+	namespaces := append([]string{"kube-system"}, common.GetDefaultQuickONNamespace()...)
+	for _, ns := range namespaces {
+		if err := o.collectNamespace(client, ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectNamespace pod 列表, 事件及容器日志片段
+func (o *Option) collectNamespace(client *k8s.Client, ns string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	dir := "k8s/" + ns
+	since := time.Now().Add(-o.Since)
+	events, err := client.ListEvents(ctx, metav1.ListOptions{FieldSelector: "involvedObject.namespace=" + ns})
+	var buf bytes.Buffer
+	if err == nil {
+		table := uitable.New()
+		table.AddRow("LAST SEEN", "TYPE", "REASON", "OBJECT", "MESSAGE")
+		for _, e := range events.Items {
+			table.AddRow(e.LastTimestamp.Format(time.RFC3339), e.Type, e.Reason, fmt.Sprintf("%s/%s", strings.ToLower(e.InvolvedObject.Kind), e.InvolvedObject.Name), strings.TrimSpace(e.Message))
+		}
+		buf.Write(table.Bytes())
+	}
+	if err := o.archive.add(dir+"/events.txt", "events of namespace "+ns, buf.Bytes(), err); err != nil {
+		return err
+	}
+	pods, err := client.ListPods(ctx, ns, metav1.ListOptions{})
+	if err != nil {
+		return o.archive.add(dir+"/pods.txt", "pods of namespace "+ns, nil, err)
+	}
+	table := uitable.New()
+	table.AddRow("NAME", "PHASE", "NODE", "RESTARTS", "AGE")
+	for _, p := range pods.Items {
+		restarts := 0
+		for _, cs := range p.Status.ContainerStatuses {
+			restarts += int(cs.RestartCount)
+		}
+		table.AddRow(p.Name, p.Status.Phase, p.Spec.NodeName, restarts, time.Since(p.CreationTimestamp.Time).Round(time.Second))
+	}
+	if err := o.archive.add(dir+"/pods.txt", "pods of namespace "+ns, table.Bytes(), nil); err != nil {
+		return err
+	}
+	for _, p := range pods.Items {
+		for _, cs := range p.Status.ContainerStatuses {
+			logs, err := getLogs(client, ns, p.Name, cs.Name, since, false)
+			if err := o.archive.add(fmt.Sprintf("%s/logs/%s_%s.log", dir, p.Name, cs.Name), "container log", []byte(logs), err); err != nil {
+				return err
+			}
+			if cs.RestartCount == 0 {
+				continue
+			}
+			logs, err = getLogs(client, ns, p.Name, cs.Name, since, true)
+			if err := o.archive.add(fmt.Sprintf("%s/logs/%s_%s.previous.log", dir, p.Name, cs.Name), "previous container log", []byte(logs), err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getLogs 获取容器最新的日志, LimitBytes 会截取 since 之后最早的部分, 因此按行数截取后再保留末尾
+func getLogs(client *k8s.Client, ns, pod, container string, since time.Time, previous bool) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	t := metav1.NewTime(since)
+	lines := int64(maxLogLines)
+	s, err := client.PodLogs(ns, pod, &corev1.PodLogOptions{
+		Container:  container,
+		Previous:   previous,
+		SinceTime:  &t,
+		TailLines:  &lines,
+		Timestamps: true,
+	}).Stream(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+	b, err := tailReader(s, maxLogBytes)
+	return string(b), err
+}
+
+// tailReader 读取全部内容, 仅保留末尾 n 字节
+func tailReader(r io.Reader, n int) ([]byte, error) {
+	var b []byte
+	buf := make([]byte, 32*1024)
+	for {
+		m, err := r.Read(buf)
+		b = append(b, buf[:m]...)
+		if len(b) > 2*n {
+			b = append(b[:0], b[len(b)-n:]...)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(b) > n {
+		b = b[len(b)-n:]
+	}
+	return b, nil
+}
+
+// releaseRevision helm release 历史版本
+type releaseRevision struct {
+	Revision    int       `json:"revision"`
+	Status      string    `json:"status"`
+	Chart       string    `json:"chart"`
+	AppVersion  string    `json:"appVersion"`
+	Description string    `json:"description"`
+	Updated     time.Time `json:"updated"`
+}
+
+func (o *Option) collectHelm(cfg *config.Config) error {
+	for _, ns := range []string{common.GetDefaultSystemNamespace(true), common.DefaultAppNamespace} {
+		hc, err := helm.NewClient(&helm.Config{Namespace: ns})
+		if err != nil {
+			return o.archive.add("helm/"+ns, "helm releases", nil, err)
+		}
+		releases, _, err := hc.List(0, 0, "")
+		if err != nil {
+			if err := o.archive.add("helm/"+ns, "helm releases", nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, r := range releases {
+			history, err := hc.History(r.Name, 10)
+			var revisions []releaseRevision
+			for _, h := range history {
+				rev := releaseRevision{Revision: h.Version}
+				if h.Info != nil {
+					rev.Status = h.Info.Status.String()
+					rev.Description = h.Info.Description
+					rev.Updated = h.Info.LastDeployed.Time
+				}
+				if h.Chart != nil && h.Chart.Metadata != nil {
+					rev.Chart = fmt.Sprintf("%s-%s", h.Chart.Metadata.Name, h.Chart.Metadata.Version)
+					rev.AppVersion = h.Chart.Metadata.AppVersion
+				}
+				revisions = append(revisions, rev)
+			}
+			if err := o.addJSON(fmt.Sprintf("helm/%s/%s.json", ns, r.Name), "helm release history", revisions, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (o *Option) collectNodes(cfg *config.Config) error {
+	if o.SkipNodes {
+		return nil
+	}
+	ips := cfg.GetIPs()
+	if len(ips) == 0 {
+		return o.archive.add("nodes", "cluster nodes", nil, errors.New("no cluster node found, only support cluster created by q"))
+	}
+	sshClient := ssh.NewSSHClient(&cfg.Global.SSH, true)
+	for _, ip := range ips {
+		for _, c := range nodeCommands {
+			out, err := runWithTimeout(sshClient, ip, c.cmd, nodeTimeout)
+			if err := o.archive.add(fmt.Sprintf("nodes/%s/%s", ip, c.name), c.desc, out, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runWithTimeout ssh 命令不支持 context, 超时后放弃等待
+func runWithTimeout(sshClient ssh.Interface, ip, cmd string, timeout time.Duration) ([]byte, error) {
+	type result struct {
+		out []byte
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		out, err := sshClient.Cmd(ip, cmd)
+		ch <- result{out, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.out, r.err
+	case <-time.After(timeout):
+		return nil, errors.Errorf("run %q timeout after %s", cmd, timeout)
+	}
+}
+
+// tailFile 读取文件末尾 n 字节
+func tailFile(path string, n int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - n
+	if offset < 0 {
+		offset = 0
+	}
+	buf := make([]byte, info.Size()-offset)
+	_, err = f.ReadAt(buf, offset)
+	return buf, err
+}
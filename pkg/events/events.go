@@ -0,0 +1,382 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/gosuri/uitable"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// releaseLabel 渠成应用实例的 release 标签
+const releaseLabel = "release"
+
+// refreshInterval 未命中时重建应用索引的最小间隔
+const refreshInterval = 30 * time.Second
+
+type Option struct {
+	App       string
+	Namespace string
+	Since     time.Duration
+	Watch     bool
+	Type      string
+	Output    output.Format
+
+	client *k8s.Client
+	index  *appIndex
+	// table watch 模式共用的输出, 表头只输出一次
+	table *watchTable
+}
+
+// Event 事件, App 为关联的渠成应用 release
+type Event struct {
+	LastSeen  time.Time `json:"lastSeen" yaml:"lastSeen"`
+	Type      string    `json:"type" yaml:"type"`
+	Reason    string    `json:"reason" yaml:"reason"`
+	Namespace string    `json:"namespace" yaml:"namespace"`
+	Kind      string    `json:"kind" yaml:"kind"`
+	Name      string    `json:"name" yaml:"name"`
+	App       string    `json:"app,omitempty" yaml:"app,omitempty"`
+	Count     int32     `json:"count" yaml:"count"`
+	Message   string    `json:"message" yaml:"message"`
+}
+
+type EventList []Event
+
+func (o *Option) Validate() error {
+	switch o.Type {
+	case "", corev1.EventTypeNormal, corev1.EventTypeWarning:
+	default:
+		return errors.Errorf("unsupported event type %s, only support %s or %s", o.Type, corev1.EventTypeNormal, corev1.EventTypeWarning)
+	}
+	if len(o.Output) == 0 {
+		o.Output = output.Table
+	}
+	if len(o.App) > 0 && len(o.Namespace) == 0 {
+		o.Namespace = common.DefaultAppNamespace
+	}
+	return nil
+}
+
+// Run 列出事件, watch 模式下持续输出新事件直到 ctx 结束
+func (o *Option) Run(ctx context.Context, out io.Writer) error {
+	client, err := k8s.NewSimpleClient()
+	if err != nil {
+		return errors.Wrap(err, "create k8s client failed")
+	}
+	o.client = client
+	return o.run(ctx, out)
+}
+
+func (o *Option) run(ctx context.Context, out io.Writer) error {
+	o.index = &appIndex{client: o.client, namespace: o.Namespace}
+	if err := o.index.refresh(ctx); err != nil {
+		return err
+	}
+	list, err := o.client.ListEvents(ctx, metav1.ListOptions{FieldSelector: o.fieldSelector()})
+	if err != nil {
+		return errors.Wrap(err, "list events failed")
+	}
+	result := o.filter(ctx, list.Items)
+	format := o.Output
+	if !o.Watch {
+		return format.Write(out, result)
+	}
+	if format == output.Table {
+		o.table = newWatchTable(out)
+		if err := o.table.write(eventHeader); err != nil {
+			return err
+		}
+	}
+	for _, ev := range result {
+		if err := o.writeOne(out, format, ev); err != nil {
+			return err
+		}
+	}
+	return o.watch(ctx, out, format, list.ResourceVersion)
+}
+
+// filter 转换事件并按 --since 及 --app 过滤, 按时间升序排列
+func (o *Option) filter(ctx context.Context, items []corev1.Event) EventList {
+	var result EventList
+	since := time.Time{}
+	if o.Since > 0 {
+		since = time.Now().Add(-o.Since)
+	}
+	for _, e := range items {
+		if ev, ok := o.convert(ctx, e); ok && !ev.LastSeen.Before(since) {
+			result = append(result, ev)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastSeen.Before(result[j].LastSeen)
+	})
+	return result
+}
+
+// watch 使用 RetryWatcher 从上次的 resourceVersion 续传, apiserver 超时关闭连接时自动重连,
+// resourceVersion 过期时重新 list 获取最新版本继续 watch
+func (o *Option) watch(ctx context.Context, out io.Writer, format output.Format, resourceVersion string) error {
+	lw := &cache.ListWatch{
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = o.fieldSelector()
+			return o.client.WatchEvents(ctx, opts)
+		},
+	}
+	for {
+		w, err := watchtools.NewRetryWatcher(resourceVersion, lw)
+		if err != nil {
+			return errors.Wrap(err, "watch events failed")
+		}
+		err = o.consume(ctx, out, format, w)
+		w.Stop()
+		if err != nil || ctx.Err() != nil {
+			return err
+		}
+		list, err := o.client.ListEvents(ctx, metav1.ListOptions{FieldSelector: o.fieldSelector(), Limit: 1})
+		if err != nil {
+			return errors.Wrap(err, "list events failed")
+		}
+		resourceVersion = list.ResourceVersion
+	}
+}
+
+// consume 输出 watch 到的事件, watch 结束或 resourceVersion 过期时返回 nil 以便重新 watch
+func (o *Option) consume(ctx context.Context, out io.Writer, format output.Format, w watch.Interface) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case we, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+			switch we.Type {
+			case watch.Added, watch.Modified:
+			case watch.Error:
+				err := apierrors.FromObject(we.Object)
+				if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					return nil
+				}
+				return errors.Wrap(err, "watch events failed")
+			default:
+				continue
+			}
+			e, ok := we.Object.(*corev1.Event)
+			if !ok {
+				continue
+			}
+			if ev, ok := o.convert(ctx, *e); ok {
+				if err := o.writeOne(out, format, ev); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// writeOne watch 模式逐条输出, json/yaml 每条一个对象, table 每行写入共用的 tabwriter 后立即刷新
+func (o *Option) writeOne(out io.Writer, format output.Format, ev Event) error {
+	switch format {
+	case output.JSON:
+		return output.EncodeJSON(out, ev)
+	case output.YAML:
+		fmt.Fprintln(out, "---")
+		return output.EncodeYAML(out, ev)
+	}
+	cells := make([]string, 0, len(eventHeader))
+	for _, c := range ev.row() {
+		cells = append(cells, strings.ReplaceAll(fmt.Sprint(c), "\n", " "))
+	}
+	return o.table.write(cells)
+}
+
+// watchTable 逐行刷新的 tabwriter 只按当前行计算列宽, 记录各列出现过的最大宽度并补齐, 避免列错位
+type watchTable struct {
+	w      *tabwriter.Writer
+	widths []int
+}
+
+func newWatchTable(out io.Writer) *watchTable {
+	return &watchTable{w: tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)}
+}
+
+func (t *watchTable) write(cells []string) error {
+	padded := make([]string, len(cells))
+	for i, c := range cells {
+		if i >= len(t.widths) {
+			t.widths = append(t.widths, 0)
+		}
+		n := utf8.RuneCountInString(c)
+		if n > t.widths[i] {
+			t.widths[i] = n
+		}
+		// 最后一列不参与对齐
+		if i < len(cells)-1 {
+			c += strings.Repeat(" ", t.widths[i]-n)
+		}
+		padded[i] = c
+	}
+	fmt.Fprintln(t.w, strings.Join(padded, "\t"))
+	return t.w.Flush()
+}
+
+func (o *Option) fieldSelector() string {
+	var selectors []string
+	if len(o.Type) > 0 {
+		selectors = append(selectors, "type="+o.Type)
+	}
+	if len(o.Namespace) > 0 {
+		selectors = append(selectors, "involvedObject.namespace="+o.Namespace)
+	}
+	return strings.Join(selectors, ",")
+}
+
+// convert 转换事件并关联应用, 指定 --app 时过滤其他事件
+func (o *Option) convert(ctx context.Context, e corev1.Event) (Event, bool) {
+	ev := Event{
+		LastSeen:  eventTime(e),
+		Type:      e.Type,
+		Reason:    e.Reason,
+		Namespace: e.InvolvedObject.Namespace,
+		Kind:      e.InvolvedObject.Kind,
+		Name:      e.InvolvedObject.Name,
+		Count:     e.Count,
+		Message:   strings.TrimSpace(e.Message),
+	}
+	ev.App = o.index.lookup(ctx, ev.Namespace, ev.Kind, ev.Name)
+	if len(o.App) > 0 && ev.App != o.App {
+		return ev, false
+	}
+	return ev, true
+}
+
+func eventTime(e corev1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+var eventHeader = []string{"LAST SEEN", "TYPE", "REASON", "OBJECT", "APP", "MESSAGE"}
+
+func (e Event) row() []interface{} {
+	return []interface{}{duration.HumanDuration(time.Since(e.LastSeen)), e.Type, e.Reason, fmt.Sprintf("%s/%s", strings.ToLower(e.Kind), e.Name), e.App, e.Message}
+}
+
+func (l EventList) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.MaxColWidth = 80
+	header := make([]interface{}, 0, len(eventHeader))
+	for _, h := range eventHeader {
+		header = append(header, h)
+	}
+	table.AddRow(header...)
+	for _, e := range l {
+		table.AddRow(e.row()...)
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (l EventList) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, l)
+}
+
+func (l EventList) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, l)
+}
+
+// appIndex 通过 release 标签将工作负载及 pod 映射到应用
+type appIndex struct {
+	client    *k8s.Client
+	namespace string
+
+	objects   map[string]string
+	workloads map[string]string
+	updated   time.Time
+}
+
+func objectKey(ns, kind, name string) string {
+	return fmt.Sprintf("%s/%s/%s", ns, kind, name)
+}
+
+func (a *appIndex) refresh(ctx context.Context) error {
+	opts := metav1.ListOptions{LabelSelector: releaseLabel}
+	objects := map[string]string{}
+	workloads := map[string]string{}
+	pods, err := a.client.ListPods(ctx, a.namespace, opts)
+	if err != nil {
+		return errors.Wrap(err, "list pods failed")
+	}
+	for _, p := range pods.Items {
+		objects[objectKey(p.Namespace, "Pod", p.Name)] = p.Labels[releaseLabel]
+	}
+	deploys, err := a.client.ListDeployments(ctx, a.namespace, opts)
+	if err != nil {
+		return errors.Wrap(err, "list deployments failed")
+	}
+	for _, d := range deploys.Items {
+		objects[objectKey(d.Namespace, "Deployment", d.Name)] = d.Labels[releaseLabel]
+		workloads[objectKey(d.Namespace, "ReplicaSet", d.Name)] = d.Labels[releaseLabel]
+	}
+	sts, err := a.client.ListStatefulSets(ctx, a.namespace, opts)
+	if err != nil {
+		return errors.Wrap(err, "list statefulsets failed")
+	}
+	for _, s := range sts.Items {
+		objects[objectKey(s.Namespace, "StatefulSet", s.Name)] = s.Labels[releaseLabel]
+	}
+	a.objects, a.workloads, a.updated = objects, workloads, time.Now()
+	return nil
+}
+
+// lookup 返回对象所属应用, 新建的 pod 未命中时按间隔重建索引
+func (a *appIndex) lookup(ctx context.Context, ns, kind, name string) string {
+	if app := a.find(ns, kind, name); len(app) > 0 {
+		return app
+	}
+	if kind == "Pod" && time.Since(a.updated) > refreshInterval {
+		if err := a.refresh(ctx); err == nil {
+			return a.find(ns, kind, name)
+		}
+	}
+	return ""
+}
+
+func (a *appIndex) find(ns, kind, name string) string {
+	if app, ok := a.objects[objectKey(ns, kind, name)]; ok {
+		return app
+	}
+	// replicaset 名称为 <deployment>-<hash>
+	if kind == "ReplicaSet" {
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			return a.workloads[objectKey(ns, kind, name[:i])]
+		}
+	}
+	return ""
+}
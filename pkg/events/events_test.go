@@ -0,0 +1,169 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/easysoft/qcadmin/internal/pkg/k8s"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func objectMeta(ns, name, release string) metav1.ObjectMeta {
+	meta := metav1.ObjectMeta{Namespace: ns, Name: name}
+	if len(release) > 0 {
+		meta.Labels = map[string]string{releaseLabel: release}
+	}
+	return meta
+}
+
+func newEvent(ns, kind, name, eventType string, lastSeen time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: ns, Name: name + "." + kind + "." + lastSeen.Format("150405")},
+		InvolvedObject: corev1.ObjectReference{Namespace: ns, Kind: kind, Name: name},
+		Type:           eventType,
+		Reason:         "Test",
+		Message:        kind + " " + name,
+		Count:          1,
+		LastTimestamp:  metav1.NewTime(lastSeen),
+	}
+}
+
+func newTestOption(o *Option, objs ...runtime.Object) *Option {
+	objs = append(objs,
+		&corev1.Pod{ObjectMeta: objectMeta("quickon-app", "web-7d9f8c6b5-x2k4q", "web")},
+		&appsv1.Deployment{ObjectMeta: objectMeta("quickon-app", "web", "web")},
+		&appsv1.Deployment{ObjectMeta: objectMeta("quickon-app", "my-api", "api")},
+		&appsv1.StatefulSet{ObjectMeta: objectMeta("quickon-app", "mysql", "db")},
+		&appsv1.Deployment{ObjectMeta: objectMeta("quickon-app", "nolabel", "")},
+	)
+	o.client = &k8s.Client{Clientset: fake.NewSimpleClientset(objs...)}
+	return o
+}
+
+func TestAppIndexFind(t *testing.T) {
+	o := newTestOption(&Option{})
+	a := &appIndex{client: o.client}
+	if err := a.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		ns   string
+		kind string
+		obj  string
+		want string
+	}{
+		{name: "pod", ns: "quickon-app", kind: "Pod", obj: "web-7d9f8c6b5-x2k4q", want: "web"},
+		{name: "deployment", ns: "quickon-app", kind: "Deployment", obj: "web", want: "web"},
+		{name: "statefulset", ns: "quickon-app", kind: "StatefulSet", obj: "mysql", want: "db"},
+		{name: "replicaset trimmed to deployment", ns: "quickon-app", kind: "ReplicaSet", obj: "web-7d9f8c6b5", want: "web"},
+		{name: "replicaset of dashed deployment", ns: "quickon-app", kind: "ReplicaSet", obj: "my-api-5c4b7f9d8", want: "api"},
+		{name: "replicaset without hash", ns: "quickon-app", kind: "ReplicaSet", obj: "web", want: ""},
+		{name: "replicaset of unknown deployment", ns: "quickon-app", kind: "ReplicaSet", obj: "other-5c4b7f9d8", want: ""},
+		{name: "replicaset in other namespace", ns: "default", kind: "ReplicaSet", obj: "web-7d9f8c6b5", want: ""},
+		{name: "workload without release label", ns: "quickon-app", kind: "Deployment", obj: "nolabel", want: ""},
+		{name: "kind mismatch", ns: "quickon-app", kind: "StatefulSet", obj: "web", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.find(tt.ns, tt.kind, tt.obj); got != tt.want {
+				t.Fatalf("find(%s, %s, %s) = %q, want %q", tt.ns, tt.kind, tt.obj, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunFilter(t *testing.T) {
+	now := time.Now()
+	events := []runtime.Object{
+		newEvent("quickon-app", "Pod", "web-7d9f8c6b5-x2k4q", corev1.EventTypeWarning, now.Add(-30*time.Minute)),
+		newEvent("quickon-app", "ReplicaSet", "web-7d9f8c6b5", corev1.EventTypeNormal, now.Add(-2*time.Minute)),
+		newEvent("quickon-app", "StatefulSet", "mysql", corev1.EventTypeNormal, now.Add(-time.Minute)),
+		newEvent("kube-system", "Pod", "coredns-abc", corev1.EventTypeNormal, now.Add(-3*time.Hour)),
+	}
+	tests := []struct {
+		name string
+		opt  Option
+		want []string
+	}{
+		{name: "all", opt: Option{}, want: []string{"coredns-abc", "web-7d9f8c6b5-x2k4q", "web-7d9f8c6b5", "mysql"}},
+		{name: "since", opt: Option{Since: 10 * time.Minute}, want: []string{"web-7d9f8c6b5", "mysql"}},
+		{name: "app", opt: Option{App: "web"}, want: []string{"web-7d9f8c6b5-x2k4q", "web-7d9f8c6b5"}},
+		{name: "app and since", opt: Option{App: "web", Since: 10 * time.Minute}, want: []string{"web-7d9f8c6b5"}},
+		{name: "unknown app", opt: Option{App: "none"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newTestOption(&tt.opt, events...)
+			o.Output = output.JSON
+			if err := o.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := o.run(context.Background(), &buf); err != nil {
+				t.Fatal(err)
+			}
+			var got EventList
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("decode %s: %v", buf.String(), err)
+			}
+			var names []string
+			for _, e := range got {
+				names = append(names, e.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("events = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchTableAligned(t *testing.T) {
+	var buf bytes.Buffer
+	table := newWatchTable(&buf)
+	rows := [][]string{
+		eventHeader,
+		{"5s", "Normal", "Scheduled", "pod/web-1", "web", "assigned"},
+		{"1m", "Warning", "BackOff", "replicaset/a-very-long-replicaset-name", "", "back-off"},
+		{"2s", "Normal", "Pulled", "pod/db", "db", "pulled"},
+	}
+	for _, r := range rows {
+		if err := table.write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != len(rows) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(rows), buf.String())
+	}
+	if strings.Count(buf.String(), "LAST SEEN") != 1 {
+		t.Fatalf("header should be printed once:\n%s", buf.String())
+	}
+	// 已输出过的列宽不会缩小, 最后一行各列起始位置不小于表头
+	header, last := lines[0], lines[len(lines)-1]
+	for _, pair := range [][2]string{{"TYPE", "Normal"}, {"REASON", "Pulled"}, {"OBJECT", "pod/db"}, {"MESSAGE", "pulled"}} {
+		h, l := strings.Index(header, pair[0]), strings.Index(last, pair[1])
+		if l < h {
+			t.Fatalf("column %s starts at %d, row value %s at %d:\n%s", pair[0], h, pair[1], l, buf.String())
+		}
+	}
+	if i, j := strings.Index(lines[2], "back-off"), strings.Index(last, "pulled"); i != j {
+		t.Fatalf("message column drifted, %d != %d:\n%s", i, j, buf.String())
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package hub
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/util/downloader"
+	qcexec "github.com/easysoft/qcadmin/internal/pkg/util/exec"
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/initsystem"
+	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/easysoft/qcadmin/internal/pkg/util/registries"
+	"github.com/easysoft/qcadmin/internal/pkg/util/ssh"
+	"github.com/ergoapi/util/exnet"
+	"github.com/ergoapi/util/file"
+	"github.com/gosuri/uitable"
+	"github.com/imroc/req/v3"
+)
+
+const (
+	AppHubService   = "q-app-hub"
+	ImageHubService = "q-image-hub"
+
+	hubDir = "/opt/quickon/hub"
+)
+
+// component hub 服务及其配置文件, 模板位于 hack/manifests/hub
+type component struct {
+	service  string
+	unit     string
+	config   string
+	template string
+}
+
+var components = []component{
+	{service: AppHubService, unit: "apphub.service", config: hubDir + "/app/config.yaml", template: "app.yaml"},
+	{service: ImageHubService, unit: "imagehub.service", config: hubDir + "/image/config.yml", template: "image.yaml"},
+}
+
+type Hub struct {
+	IP  string
+	log log.Logger
+}
+
+func New(f factory.Factory) *Hub {
+	return &Hub{
+		log: f.GetLog(),
+	}
+}
+
+// Address hub 地址, 未指定时使用已保存地址或本机 ip
+func (h *Hub) Address() string {
+	if len(h.IP) > 0 {
+		return h.IP
+	}
+	cfg, _ := config.LoadConfig()
+	if cfg != nil && len(cfg.Hub.Address) > 0 {
+		return cfg.Hub.Address
+	}
+	return exnet.LocalIPs()[0]
+}
+
+func (h *Hub) appURL() string {
+	return fmt.Sprintf("http://127.0.0.1:%d", common.HubAppPort)
+}
+
+func (h *Hub) imageURL() string {
+	return fmt.Sprintf("http://127.0.0.1:%d", common.HubImagePort)
+}
+
+// Init 安装并启动 chart 仓库及镜像仓库服务, 并为集群节点配置镜像加速
+func (h *Hub) Init() error {
+	is, err := initsystem.GetInitSystem()
+	if err != nil {
+		return err
+	}
+	if _, ok := is.(*initsystem.SystemdInitSystem); !ok {
+		return errors.New("hub only support systemd")
+	}
+	for _, c := range components {
+		if err := h.installBin(c.service); err != nil {
+			return err
+		}
+		tplDir := fmt.Sprintf("%s/hack/manifests/hub", common.GetDefaultDataDir())
+		if err := copyFile(filepath.Join(tplDir, c.template), c.config); err != nil {
+			return errors.Wrapf(err, "stage %s config failed", c.service)
+		}
+		if err := copyFile(filepath.Join(tplDir, c.unit), fmt.Sprintf("/etc/systemd/system/%s.service", c.service)); err != nil {
+			return errors.Wrapf(err, "stage %s service failed", c.service)
+		}
+		if out, err := qcexec.CommandBashRunWithResp(is.EnableCommand(c.service)); err != nil {
+			return errors.Errorf("enable %s failed: %s", c.service, out)
+		}
+		if err := is.ServiceRestart(c.service); err != nil {
+			return errors.Wrapf(err, "start %s failed", c.service)
+		}
+		h.log.Donef("start %s success", c.service)
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.Hub.Address = h.Address()
+	if err := cfg.SaveConfig(); err != nil {
+		return err
+	}
+	if err := h.waitReady(time.Minute); err != nil {
+		return err
+	}
+	return h.SyncRegistries()
+}
+
+// installBin 优先使用离线包内的二进制, 否则在线下载
+func (h *Hub) installBin(name string) error {
+	dst := fmt.Sprintf("/usr/bin/%s", name)
+	if file.CheckFileExists(dst) {
+		return nil
+	}
+	staged := fmt.Sprintf("%s/hack/bin/%s-linux-%s", common.GetDefaultDataDir(), name, runtime.GOARCH)
+	src := staged
+	if !file.CheckFileExists(staged) {
+		src = common.GetBinURL(name)
+	}
+	h.log.Infof("install %s from %s", name, src)
+	if _, err := downloader.Download(src, dst); err != nil {
+		return errors.Wrapf(err, "install %s failed", name)
+	}
+	return os.Chmod(dst, common.FileMode0755)
+}
+
+func (h *Hub) waitReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		var notReady []string
+		for _, s := range h.Status() {
+			if !s.Healthy {
+				notReady = append(notReady, s.Name)
+			}
+		}
+		if len(notReady) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("hub %v not ready", notReady)
+		}
+		time.Sleep(3 * time.Second)
+	}
+}
+
+// ServiceStatus hub 服务状态
+type ServiceStatus struct {
+	Name     string `json:"name" yaml:"name"`
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	Active   bool   `json:"active" yaml:"active"`
+	Healthy  bool   `json:"healthy" yaml:"healthy"`
+}
+
+type ServiceStatusList []ServiceStatus
+
+func (l ServiceStatusList) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.AddRow("SERVICE", "ENDPOINT", "ACTIVE", "HEALTHY")
+	for _, s := range l {
+		table.AddRow(s.Name, s.Endpoint, s.Active, s.Healthy)
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (l ServiceStatusList) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, l)
+}
+
+func (l ServiceStatusList) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, l)
+}
+
+// Status 检查服务运行状态及健康检查接口
+func (h *Hub) Status() ServiceStatusList {
+	is, _ := initsystem.GetInitSystem()
+	client := req.C().SetLogger(nil).SetTimeout(5 * time.Second)
+	checks := []struct {
+		name     string
+		endpoint string
+		health   string
+	}{
+		{AppHubService, fmt.Sprintf("http://%s:%d", h.Address(), common.HubAppPort), h.appURL() + "/health"},
+		{ImageHubService, fmt.Sprintf("%s:%d", h.Address(), common.HubImagePort), h.imageURL() + "/v2/"},
+	}
+	var result ServiceStatusList
+	for _, c := range checks {
+		s := ServiceStatus{Name: c.name, Endpoint: c.endpoint}
+		if is != nil {
+			s.Active = is.ServiceIsActive(c.name)
+		}
+		if resp, err := client.R().Get(c.health); err == nil && resp.IsSuccessState() {
+			s.Healthy = true
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// Registries 生成 k3s registries.yaml, 合并用户配置的 mirror 及认证, 常用仓库均指向 hub
+func (h *Hub) Registries(base *registries.Registries) *registries.Registries {
+	endpoint := fmt.Sprintf("http://%s:%d", h.Address(), common.HubImagePort)
+	r := &registries.Registries{}
+	r.Merge(base)
+	for _, name := range []string{"docker.io", "hub.qucheng.com", fmt.Sprintf("%s:%d", h.Address(), common.HubImagePort), fmt.Sprintf("127.0.0.1:%d", common.HubImagePort)} {
+		r.AddMirror(name, endpoint)
+	}
+	return r
+}
+
+// SyncRegistries 写入所有节点的 registries.yaml, k3s 重启后生效
+func (h *Hub) SyncRegistries() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	data, err := h.Registries(cfg.Cluster.Registries).Marshal()
+	if err != nil {
+		return err
+	}
+	ips := cfg.GetIPs()
+	if len(ips) == 0 {
+		return os.WriteFile(registries.Path, data, common.FileMode0644)
+	}
+	src := fmt.Sprintf("%s/registries.yaml", common.GetDefaultCacheDir())
+	if err := os.WriteFile(src, data, common.FileMode0644); err != nil {
+		return err
+	}
+	sshClient := ssh.NewSSHClient(&cfg.Global.SSH, true)
+	for _, ip := range ips {
+		if err := sshClient.Copy(ip, src, registries.Path); err != nil {
+			return errors.Errorf("copy registries.yaml to %s failed, reason: %v", ip, err)
+		}
+		h.log.Donef("sync registries.yaml to %s", ip)
+	}
+	h.log.Infof("registries.yaml takes effect after k3s restart")
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), common.FileMode0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, common.FileMode0644)
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package hub
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/ergoapi/util/file"
+	"github.com/gosuri/uitable"
+	"github.com/imroc/req/v3"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Push 推送 chart 包(.tgz), 镜像包(.tar/.tar.zst) 或本地镜像到 hub, 镜像经由本机 k3s containerd 推送
+func (h *Hub) Push(targets []string) error {
+	for _, t := range targets {
+		if !strings.HasSuffix(t, ".tgz") {
+			if err := checkContainerd(); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	for _, t := range targets {
+		var err error
+		switch {
+		case strings.HasSuffix(t, ".tgz"):
+			err = h.pushChart(t)
+		case strings.HasSuffix(t, ".tar"), strings.HasSuffix(t, ".tar.zst"):
+			err = h.pushImageArchive(t)
+		default:
+			err = h.pushImage(t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PushStaged 推送 q offline load 放置的全部镜像包
+func (h *Hub) PushStaged() error {
+	archives, _ := filepath.Glob(filepath.Join(common.K3sAirgapImagesDir, "*.tar*"))
+	if len(archives) == 0 {
+		return errors.Errorf("no image archive found in %s", common.K3sAirgapImagesDir)
+	}
+	return h.Push(archives)
+}
+
+func (h *Hub) pushChart(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	client := req.C().SetLogger(nil).SetTimeout(time.Minute)
+	resp, err := client.R().SetBody(data).Post(h.appURL() + "/api/charts")
+	if err != nil {
+		return errors.Wrapf(err, "push chart %s failed", path)
+	}
+	if resp.StatusCode == http.StatusConflict {
+		h.log.Warnf("chart %s already exists", filepath.Base(path))
+		return nil
+	}
+	if !resp.IsSuccessState() {
+		return errors.Errorf("push chart %s failed, status: %s", path, resp.Status)
+	}
+	h.log.Donef("push chart %s success", filepath.Base(path))
+	return nil
+}
+
+// checkContainerd 推送镜像依赖本机 k3s 内置的 containerd, 需先完成 q init
+func checkContainerd() error {
+	if !file.CheckFileExists(common.K3sBinPath) {
+		return errors.Errorf("push images requires k3s on this host, %s not found, run `q init --offline` first, charts can be pushed before that", common.K3sBinPath)
+	}
+	if out, err := ctr("version"); err != nil {
+		return errors.Errorf("push images requires k3s containerd running on this host: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func ctr(args ...string) ([]byte, error) {
+	// #nosec
+	cmd := exec.Command(common.K3sBinPath, append([]string{"ctr", "-n", "k8s.io"}, args...)...)
+	return cmd.CombinedOutput()
+}
+
+func (h *Hub) pushImageArchive(path string) error {
+	if strings.HasSuffix(path, ".zst") {
+		tmp, err := decompress(path)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp)
+		path = tmp
+	}
+	h.log.StartWait(fmt.Sprintf("import images from %s", path))
+	out, err := ctr("images", "import", path)
+	h.log.StopWait()
+	if err != nil {
+		return errors.Errorf("import %s failed: %s", path, string(out))
+	}
+	images := importedImages(string(out))
+	for i, img := range images {
+		h.log.Infof("[%d/%d] push %s", i+1, len(images), img)
+		if err := h.pushLocalImage(img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Hub) pushImage(ref string) error {
+	ref = normalizeImage(ref)
+	if out, _ := ctr("images", "ls", "-q", "name=="+ref); len(strings.TrimSpace(string(out))) == 0 {
+		h.log.StartWait(fmt.Sprintf("pull %s", ref))
+		out, err := ctr("images", "pull", ref)
+		h.log.StopWait()
+		if err != nil {
+			return errors.Errorf("pull %s failed: %s", ref, string(out))
+		}
+	}
+	return h.pushLocalImage(ref)
+}
+
+func (h *Hub) pushLocalImage(ref string) error {
+	target := fmt.Sprintf("127.0.0.1:%d/%s", common.HubImagePort, imagePath(ref))
+	if out, err := ctr("images", "tag", "--force", ref, target); err != nil {
+		return errors.Errorf("tag %s failed: %s", ref, string(out))
+	}
+	if out, err := ctr("images", "push", "--plain-http", target); err != nil {
+		return errors.Errorf("push %s failed: %s", ref, string(out))
+	}
+	h.log.Donef("push image %s success", ref)
+	return nil
+}
+
+// importedImages 解析 ctr images import 输出, 如 unpacking docker.io/library/nginx:1.25 (sha256:...)...done
+func importedImages(out string) []string {
+	var images []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "unpacking ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 1 {
+			images = append(images, fields[1])
+		}
+	}
+	return images
+}
+
+// normalizeImage 补全镜像仓库及 tag, nginx -> docker.io/library/nginx:latest
+func normalizeImage(ref string) string {
+	name := ref
+	if i := strings.Index(name, "/"); i < 0 || !strings.ContainsAny(name[:i], ".:") && name[:i] != "localhost" {
+		if i < 0 {
+			name = "library/" + name
+		}
+		name = "docker.io/" + name
+	}
+	if !strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") && !strings.Contains(name, "@") {
+		name += ":latest"
+	}
+	return name
+}
+
+// imagePath 去掉仓库地址, hub 作为各仓库的 mirror 使用相同路径
+func imagePath(ref string) string {
+	ref = normalizeImage(ref)
+	return ref[strings.Index(ref, "/")+1:]
+}
+
+func decompress(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	zr, err := zstd.NewReader(in)
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+	out, err := os.CreateTemp("", "qhub-*.tar")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, zr); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// Catalog hub 中的镜像及 chart
+type Catalog struct {
+	Images []string            `json:"images" yaml:"images"`
+	Charts map[string][]string `json:"charts" yaml:"charts"`
+}
+
+func (c Catalog) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.MaxColWidth = 80
+	table.Wrap = true
+	table.AddRow("TYPE", "NAME", "VERSIONS")
+	var names []string
+	for name := range c.Charts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		table.AddRow("chart", name, strings.Join(c.Charts[name], ","))
+	}
+	for _, img := range c.Images {
+		table.AddRow("image", img, "-")
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (c Catalog) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, c)
+}
+
+func (c Catalog) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, c)
+}
+
+// List 列出 hub 中的镜像及 chart 版本
+func (h *Hub) List() (*Catalog, error) {
+	client := req.C().SetLogger(nil).SetTimeout(30 * time.Second)
+	c := &Catalog{Charts: map[string][]string{}}
+	var repos struct {
+		Repositories []string `json:"repositories"`
+	}
+	resp, err := client.R().SetSuccessResult(&repos).Get(h.imageURL() + "/v2/_catalog?n=10000")
+	if err != nil || !resp.IsSuccessState() {
+		return nil, errors.Errorf("list images from %s failed, check: q hub status", ImageHubService)
+	}
+	for _, repo := range repos.Repositories {
+		var tags struct {
+			Tags []string `json:"tags"`
+		}
+		if resp, err := client.R().SetSuccessResult(&tags).Get(fmt.Sprintf("%s/v2/%s/tags/list", h.imageURL(), repo)); err != nil || !resp.IsSuccessState() {
+			continue
+		}
+		for _, t := range tags.Tags {
+			c.Images = append(c.Images, fmt.Sprintf("%s:%s", repo, t))
+		}
+	}
+	sort.Strings(c.Images)
+	var charts map[string][]struct {
+		Version string `json:"version"`
+	}
+	resp, err = client.R().SetSuccessResult(&charts).Get(h.appURL() + "/api/charts")
+	if err != nil || !resp.IsSuccessState() {
+		return nil, errors.Errorf("list charts from %s failed, check: q hub status", AppHubService)
+	}
+	for name, versions := range charts {
+		for _, v := range versions {
+			c.Charts[name] = append(c.Charts[name], v.Version)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package hub
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeImage(t *testing.T) {
+	tests := []struct {
+		ref, want, path string
+	}{
+		{"nginx", "docker.io/library/nginx:latest", "library/nginx:latest"},
+		{"nginx:1.25", "docker.io/library/nginx:1.25", "library/nginx:1.25"},
+		{"bitnami/redis:7.0", "docker.io/bitnami/redis:7.0", "bitnami/redis:7.0"},
+		{"hub.qucheng.com/app/zentao:18.0", "hub.qucheng.com/app/zentao:18.0", "app/zentao:18.0"},
+		{"localhost/demo", "localhost/demo:latest", "demo:latest"},
+		{"127.0.0.1:5000/demo:v1", "127.0.0.1:5000/demo:v1", "demo:v1"},
+		{"registry:5000/demo", "registry:5000/demo:latest", "demo:latest"},
+		{"nginx@sha256:abcd", "docker.io/library/nginx@sha256:abcd", "library/nginx@sha256:abcd"},
+	}
+	for _, tt := range tests {
+		if got := normalizeImage(tt.ref); got != tt.want {
+			t.Errorf("normalizeImage(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+		if got := imagePath(tt.ref); got != tt.path {
+			t.Errorf("imagePath(%q) = %q, want %q", tt.ref, got, tt.path)
+		}
+	}
+}
+
+func TestImportedImages(t *testing.T) {
+	out := `unpacking docker.io/library/nginx:1.25 (sha256:aaaa)...done
+unpacking hub.qucheng.com/app/zentao:18.0 (sha256:bbbb)...done
+some other output
+`
+	want := []string{"docker.io/library/nginx:1.25", "hub.qucheng.com/app/zentao:18.0"}
+	if got := importedImages(out); !reflect.DeepEqual(got, want) {
+		t.Fatalf("importedImages() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package offline
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		manifestFile:           "apiVersion: v1\n",
+		"charts/demo-1.0.tgz":  "chart",
+		"bin/k3s-linux-amd64":  "k3s",
+		"plugins/plugins.json": "{}",
+	}
+	for name, content := range files {
+		if err := writeFile(filepath.Join(src, name), []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.zst")
+	if err := archiveDir(src, bundle); err != nil {
+		t.Fatal(err)
+	}
+	if err := extractArchive(bundle, dst); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		b, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil || string(b) != content {
+			t.Errorf("%s = %q, %v, want %q", name, b, err, content)
+		}
+	}
+}
+
+func TestExtractArchiveRejectsTraversal(t *testing.T) {
+	for _, name := range []string{"../evil", "charts/../../evil", "/../evil"} {
+		bundle := filepath.Join(t.TempDir(), "bundle.tar.zst")
+		writeTestArchive(t, bundle, name, "evil")
+		dst := filepath.Join(t.TempDir(), "dst")
+		if err := extractArchive(bundle, dst); err == nil {
+			t.Errorf("extract %q: expect illegal path error", name)
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(dst), "evil")); err == nil {
+			t.Errorf("extract %q: file written outside of dst", name)
+		}
+	}
+}
+
+func writeTestArchive(t *testing.T, path, name, content string) {
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	zw, err := zstd.NewWriter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(zw)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
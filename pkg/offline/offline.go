@@ -0,0 +1,365 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package offline
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/pkg/plugin"
+	"github.com/easysoft/qcadmin/internal/pkg/util/downloader"
+	qcexec "github.com/easysoft/qcadmin/internal/pkg/util/exec"
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
+	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/easysoft/qcadmin/pkg/hub"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	manifestFile      = "manifest.yaml"
+	bundleAPIVersion  = "v1"
+	quickonImagesFile = "images/quickon-images.tar"
+	pluginIndexFile   = "plugins/plugins.json"
+	// marketRepoName 应用市场 chart 仓库, 始终使用 stable 渠道
+	marketRepoName = "offline-market"
+)
+
+// Manifest 离线包清单
+type Manifest struct {
+	APIVersion string    `json:"apiVersion"`
+	Version    string    `json:"version"`
+	CLIVersion string    `json:"cliVersion"`
+	Arch       string    `json:"arch"`
+	Created    time.Time `json:"created"`
+	K3s        string    `json:"k3s"`
+	Bins       []string  `json:"bins,omitempty"`
+	Charts     []Chart   `json:"charts"`
+	Images     []string  `json:"images"`
+	Archives   []string  `json:"archives"`
+	Plugins    string    `json:"plugins"`
+}
+
+// Chart 离线包内的 chart
+type Chart struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	File    string `json:"file"`
+	App     bool   `json:"app,omitempty"`
+}
+
+type Option struct {
+	Version    string
+	Output     string
+	Arch       string
+	SkipImages bool
+	// Apps 打包的应用市场应用, 离线安装后可直接安装
+	Apps []string
+	log  log.Logger
+}
+
+func New(f factory.Factory) *Option {
+	return &Option{
+		log:     f.GetLog(),
+		Version: common.DefaultQuickonOssVersion,
+		Output:  "bundle.tar.zst",
+		Arch:    runtime.GOARCH,
+		Apps:    []string{"zentao"},
+	}
+}
+
+// Build 收集 k3s, chart, 镜像及插件索引生成离线包
+func (o *Option) Build() error {
+	if o.Arch != "amd64" && o.Arch != "arm64" {
+		return errors.Errorf("arch %s not support, only amd64 or arm64", o.Arch)
+	}
+	if !o.SkipImages {
+		if _, err := exec.LookPath("docker"); err != nil {
+			return errors.New("docker is required to save images, or use --skip-images")
+		}
+	}
+	work, err := os.MkdirTemp("", "qoffline")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+	m := &Manifest{
+		APIVersion: bundleAPIVersion,
+		Version:    o.Version,
+		CLIVersion: common.Version,
+		Arch:       o.Arch,
+		Created:    time.Now(),
+	}
+	if err := o.fetchBins(work, m); err != nil {
+		return err
+	}
+	if err := o.fetchCharts(work, m); err != nil {
+		return err
+	}
+	if err := o.fetchApps(work, m); err != nil {
+		return err
+	}
+	if !o.SkipImages {
+		if err := o.saveImages(work, m); err != nil {
+			return err
+		}
+	}
+	idx, err := plugin.LoadIndex()
+	if err != nil {
+		return errors.Wrap(err, "load plugin index failed")
+	}
+	idx.Source = "offline"
+	data, _ := json.MarshalIndent(idx, "", "  ")
+	if err := writeFile(filepath.Join(work, pluginIndexFile), data); err != nil {
+		return err
+	}
+	m.Plugins = pluginIndexFile
+	data, _ = yaml.Marshal(m)
+	if err := writeFile(filepath.Join(work, manifestFile), data); err != nil {
+		return err
+	}
+	o.log.StartWait(fmt.Sprintf("write bundle %s", o.Output))
+	err = archiveDir(work, o.Output)
+	o.log.StopWait()
+	if err != nil {
+		return errors.Wrapf(err, "write bundle %s failed", o.Output)
+	}
+	o.log.Donef("build offline bundle %s success, charts: %d, images: %d", o.Output, len(m.Charts), len(m.Images))
+	return nil
+}
+
+// fetchBins 下载 k3s 及其系统镜像, 离线 hub 服务
+func (o *Option) fetchBins(work string, m *Manifest) error {
+	release := fmt.Sprintf("%s/%s", common.K3sBinURL, url.PathEscape(common.K3sBinVersion))
+	bin := "k3s"
+	if o.Arch == "arm64" {
+		bin = "k3s-arm64"
+	}
+	m.K3s = fmt.Sprintf("bin/k3s-linux-%s", o.Arch)
+	o.log.Infof("download k3s %s", common.K3sBinVersion)
+	if _, err := downloader.Download(fmt.Sprintf("%s/%s", release, bin), filepath.Join(work, m.K3s)); err != nil {
+		return errors.Wrap(err, "download k3s failed")
+	}
+	airgap := fmt.Sprintf("k3s-airgap-images-%s.tar.zst", o.Arch)
+	o.log.Infof("download k3s system images")
+	if _, err := downloader.Download(fmt.Sprintf("%s/%s", release, airgap), filepath.Join(work, "images", airgap)); err != nil {
+		return errors.Wrap(err, "download k3s airgap images failed")
+	}
+	m.Archives = append(m.Archives, filepath.Join("images", airgap))
+	for _, name := range []string{hub.AppHubService, hub.ImageHubService} {
+		bin := fmt.Sprintf("bin/%s-linux-%s", name, o.Arch)
+		o.log.Infof("download %s", name)
+		if _, err := downloader.Download(common.GetBinURLWithArch(name, o.Arch), filepath.Join(work, bin)); err != nil {
+			return errors.Wrapf(err, "download %s failed", name)
+		}
+		m.Bins = append(m.Bins, bin)
+	}
+	return nil
+}
+
+// fetchCharts 下载安装源内全部 chart 的最新版本, 渠成按版本号固定
+func (o *Option) fetchCharts(work string, m *Manifest) error {
+	hc, err := helm.NewClient(&helm.Config{Namespace: common.GetDefaultSystemNamespace(true), Progress: o.log.Debugf})
+	if err != nil {
+		return errors.Wrap(err, "create helm client failed")
+	}
+	if err := hc.InitRepo(common.DefaultHelmRepoName, common.GetChartRepo(o.Version)); err != nil {
+		return err
+	}
+	charts, err := hc.ListCharts(common.DefaultHelmRepoName, "", false)
+	if err != nil {
+		return err
+	}
+	pinned := map[string]string{
+		common.GetQuickONName(common.QuickonOSSType): common.GetVersion(o.Version, common.QuickonOSSType),
+		common.GetQuickONName(common.QuickonEEType):  common.GetVersion(o.Version, common.QuickonEEType),
+	}
+	for _, c := range charts {
+		version := c.Chart.Version
+		if v, ok := pinned[c.Chart.Name]; ok {
+			version = v
+		}
+		o.log.StartWait(fmt.Sprintf("pull chart %s-%s", c.Chart.Name, version))
+		p, err := hc.Pull(common.DefaultHelmRepoName, c.Chart.Name, version, filepath.Join(work, "charts"))
+		o.log.StopWait()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(work, p)
+		m.Charts = append(m.Charts, Chart{Name: c.Chart.Name, Version: version, File: rel})
+	}
+	o.log.Donef("pull %d charts success", len(m.Charts))
+	return nil
+}
+
+// fetchApps 下载应用市场中指定应用的最新 chart, 安装源内已包含的跳过
+func (o *Option) fetchApps(work string, m *Manifest) error {
+	if len(o.Apps) == 0 {
+		return nil
+	}
+	hc, err := helm.NewClient(&helm.Config{Namespace: common.DefaultAppNamespace, Progress: o.log.Debugf})
+	if err != nil {
+		return errors.Wrap(err, "create helm client failed")
+	}
+	if err := hc.InitRepo(marketRepoName, common.GetChartRepo("")); err != nil {
+		return err
+	}
+	for _, app := range o.Apps {
+		exist := false
+		for i, c := range m.Charts {
+			if c.Name == app {
+				m.Charts[i].App = true
+				exist = true
+			}
+		}
+		if exist {
+			continue
+		}
+		o.log.StartWait(fmt.Sprintf("pull app chart %s", app))
+		p, err := hc.Pull(marketRepoName, app, "", filepath.Join(work, "charts"))
+		o.log.StopWait()
+		if err != nil {
+			return errors.Wrapf(err, "pull app chart %s failed", app)
+		}
+		ct, err := loader.Load(p)
+		if err != nil {
+			return errors.Wrapf(err, "load app chart %s failed", app)
+		}
+		rel, _ := filepath.Rel(work, p)
+		m.Charts = append(m.Charts, Chart{Name: app, Version: ct.Metadata.Version, File: rel, App: true})
+	}
+	o.log.Donef("pull %d app charts success", len(o.Apps))
+	return nil
+}
+
+// saveImages 渲染全部 chart 提取镜像, 使用 docker 拉取并导出
+func (o *Option) saveImages(work string, m *Manifest) error {
+	found := map[string]bool{}
+	for _, c := range m.Charts {
+		ct, err := loader.Load(filepath.Join(work, c.File))
+		if err != nil {
+			o.log.Warnf("load chart %s failed, skip images: %v", c.Name, err)
+			continue
+		}
+		ns := common.GetDefaultSystemNamespace(true)
+		if c.App {
+			ns = common.DefaultAppNamespace
+		}
+		manifest, err := helm.RenderChart(ct, ns, nil)
+		if err != nil {
+			o.log.Warnf("skip images of chart %s: %v", c.Name, err)
+			continue
+		}
+		for _, img := range helm.ManifestImages(manifest) {
+			if !found[img] {
+				found[img] = true
+				m.Images = append(m.Images, img)
+			}
+		}
+	}
+	if len(m.Images) == 0 {
+		return nil
+	}
+	for i, img := range m.Images {
+		o.log.Infof("[%d/%d] pull image %s", i+1, len(m.Images), img)
+		if err := qcexec.CommandRun("docker", "pull", "--platform", "linux/"+o.Arch, img); err != nil {
+			return errors.Errorf("pull image %s failed, reason: %v", img, err)
+		}
+	}
+	dst := filepath.Join(work, quickonImagesFile)
+	if err := os.MkdirAll(filepath.Dir(dst), common.FileMode0755); err != nil {
+		return err
+	}
+	o.log.StartWait(fmt.Sprintf("save %d images", len(m.Images)))
+	err := qcexec.CommandRun("docker", append([]string{"save", "-o", dst}, m.Images...)...)
+	o.log.StopWait()
+	if err != nil {
+		return errors.Errorf("save images failed, reason: %v", err)
+	}
+	m.Archives = append(m.Archives, quickonImagesFile)
+	return nil
+}
+
+// Load 解压离线包并放置到离线安装所需位置
+func (o *Option) Load(bundle string) error {
+	if os.Geteuid() != 0 {
+		return errors.New("load offline bundle requires root")
+	}
+	work, err := os.MkdirTemp("", "qoffline")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+	o.log.StartWait(fmt.Sprintf("extract bundle %s", bundle))
+	err = extractArchive(bundle, work)
+	o.log.StopWait()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(work, manifestFile))
+	if err != nil {
+		return errors.Wrap(err, "bundle manifest not found")
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return errors.Wrap(err, "parse bundle manifest failed")
+	}
+	if m.APIVersion != bundleAPIVersion {
+		return errors.Errorf("bundle apiVersion %s not support", m.APIVersion)
+	}
+	if m.Arch != runtime.GOARCH {
+		return errors.Errorf("bundle arch %s does not match host arch %s", m.Arch, runtime.GOARCH)
+	}
+	k3sbin := fmt.Sprintf("%s/hack/bin/k3s-linux-%s", common.GetDefaultDataDir(), m.Arch)
+	if err := copyFile(filepath.Join(work, m.K3s), k3sbin, common.FileMode0755); err != nil {
+		return errors.Wrap(err, "stage k3s failed")
+	}
+	o.log.Donef("stage k3s to %s", k3sbin)
+	for _, b := range m.Bins {
+		if err := copyFile(filepath.Join(work, b), fmt.Sprintf("%s/hack/%s", common.GetDefaultDataDir(), b), common.FileMode0755); err != nil {
+			return errors.Wrapf(err, "stage %s failed", b)
+		}
+	}
+	for _, a := range m.Archives {
+		if err := copyFile(filepath.Join(work, a), filepath.Join(common.K3sAirgapImagesDir, filepath.Base(a)), common.FileMode0644); err != nil {
+			return errors.Wrapf(err, "stage images %s failed", a)
+		}
+	}
+	o.log.Donef("stage %d images to %s", len(m.Images), common.K3sAirgapImagesDir)
+	for _, c := range m.Charts {
+		if err := copyFile(filepath.Join(work, c.File), filepath.Join(common.HubAppDataDir, filepath.Base(c.File)), common.FileMode0644); err != nil {
+			return errors.Wrapf(err, "stage chart %s failed", c.Name)
+		}
+	}
+	o.log.Donef("stage %d charts to %s", len(m.Charts), common.HubAppDataDir)
+	if len(m.Plugins) > 0 {
+		if err := copyFile(filepath.Join(work, m.Plugins), common.GetPluginIndexCache(), common.FileMode0644); err != nil {
+			return errors.Wrap(err, "stage plugin index failed")
+		}
+	}
+	if err := copyFile(filepath.Join(work, manifestFile), filepath.Join(common.GetDefaultDataDir(), "offline", manifestFile), common.FileMode0644); err != nil {
+		return err
+	}
+	o.log.Donef("load offline bundle %s (quickon %s) success, now you can run: %s hub init && %s init --offline", bundle, m.Version, os.Args[0], os.Args[0])
+	return nil
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), common.FileMode0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, common.FileMode0644)
+}
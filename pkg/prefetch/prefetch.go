@@ -0,0 +1,129 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package prefetch
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
+	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/easysoft/qcadmin/internal/pkg/util/ssh"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+type Option struct {
+	Repo    string
+	Chart   string
+	Version string
+	// Nodes 为空时使用集群全部节点
+	Nodes []string
+	log   log.Logger
+}
+
+func New(f factory.Factory) *Option {
+	return &Option{
+		Repo: common.DefaultHelmRepoName,
+		log:  f.GetLog(),
+	}
+}
+
+// ParseChart 解析 chart[@version]
+func (o *Option) ParseChart(s string) {
+	o.Chart, o.Version, _ = strings.Cut(s, "@")
+}
+
+// Images 下载并渲染 chart, 返回其使用的镜像
+func (o *Option) Images() ([]string, error) {
+	hc, err := helm.NewClient(&helm.Config{Namespace: common.DefaultAppNamespace})
+	if err != nil {
+		return nil, errors.Wrap(err, "create helm client failed")
+	}
+	dir, err := os.MkdirTemp("", "qprefetch-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	p, err := hc.Pull(o.Repo, o.Chart, o.Version, dir)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := loader.Load(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load chart %s failed", o.Chart)
+	}
+	manifest, err := helm.RenderChart(ct, common.DefaultAppNamespace, nil)
+	if err != nil {
+		return nil, err
+	}
+	return helm.ManifestImages(manifest), nil
+}
+
+// Run 在每个节点上通过 crictl 预拉取镜像, 节点间并行
+func (o *Option) Run() error {
+	images, err := o.Images()
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		o.log.Warnf("no image found in chart %s", o.Chart)
+		return nil
+	}
+	o.log.Infof("chart %s uses %d images", o.Chart, len(images))
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	nodes := o.Nodes
+	if len(nodes) == 0 {
+		nodes = cfg.GetIPs()
+	}
+	if len(nodes) == 0 {
+		return errors.New("no cluster node found, only support cluster created by q")
+	}
+	sshClient := ssh.NewSSHClient(&cfg.Global.SSH, true)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
+	for _, ip := range nodes {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			if err := o.pull(sshClient, ip, images); err != nil {
+				o.log.Warnf("%s: %v", ip, err)
+				mu.Lock()
+				failed = append(failed, ip)
+				mu.Unlock()
+				return
+			}
+			o.log.Donef("%s: %d images ready", ip, len(images))
+		}(ip)
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		return errors.Errorf("prefetch images failed on node %s", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+func (o *Option) pull(sshClient ssh.Interface, ip string, images []string) error {
+	for i, img := range images {
+		o.log.Infof("%s: [%d/%d] pull %s", ip, i+1, len(images), img)
+		// 已存在的镜像跳过
+		cmd := fmt.Sprintf("%s crictl inspecti -q %s >/dev/null 2>&1 || %s crictl pull %s", common.K3sBinPath, img, common.K3sBinPath, img)
+		if out, err := sshClient.Cmd(ip, cmd); err != nil {
+			return errors.Errorf("pull %s failed: %s", img, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
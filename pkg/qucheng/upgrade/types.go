@@ -6,19 +6,43 @@
 
 package upgrade
 
+import (
+	"io"
+
+	"github.com/easysoft/qcadmin/internal/pkg/util/output"
+	"github.com/gosuri/uitable"
+)
+
 type Version struct {
-	Components []ComponentVersion `json:",omitempty"`
+	Components []ComponentVersion `json:"components,omitempty" yaml:"components,omitempty"`
 }
 
 type ComponentVersion struct {
-	Name           string
-	Deploy         CVersion
-	Remote         CVersion
-	CanUpgrade     bool   `json:",omitempty"`
-	UpgradeMessage string `json:",omitempty"`
+	Name           string   `json:"name" yaml:"name"`
+	Deploy         CVersion `json:"deploy" yaml:"deploy"`
+	Remote         CVersion `json:"remote" yaml:"remote"`
+	CanUpgrade     bool     `json:"canUpgrade" yaml:"canUpgrade"`
+	UpgradeMessage string   `json:"upgradeMessage,omitempty" yaml:"upgradeMessage,omitempty"`
 }
 
 type CVersion struct {
-	AppVersion   string
-	ChartVersion string
+	AppVersion   string `json:"appVersion" yaml:"appVersion"`
+	ChartVersion string `json:"chartVersion" yaml:"chartVersion"`
+}
+
+func (v Version) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.AddRow("COMPONENT", "APP VERSION", "CHART VERSION", "REMOTE APP VERSION", "REMOTE CHART VERSION", "UPGRADE")
+	for _, c := range v.Components {
+		table.AddRow(c.Name, c.Deploy.AppVersion, c.Deploy.ChartVersion, c.Remote.AppVersion, c.Remote.ChartVersion, c.CanUpgrade)
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (v Version) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, v)
+}
+
+func (v Version) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, v)
 }
@@ -14,7 +14,6 @@ import (
 	"github.com/easysoft/qcadmin/common"
 	"github.com/easysoft/qcadmin/internal/app/config"
 	suffixdomain "github.com/easysoft/qcadmin/pkg/qucheng/domain"
-	"github.com/ergoapi/util/color"
 
 	"github.com/cockroachdb/errors"
 	qcexec "github.com/easysoft/qcadmin/internal/pkg/util/exec"
@@ -60,7 +59,7 @@ func (opt *Option) Fetch(ns, name string) (ComponentVersion, error) {
 	// can upgrade
 	cmv.CanUpgrade = version.LT(cmv.Remote.ChartVersion, cmv.Deploy.ChartVersion)
 	if cmv.CanUpgrade {
-		cmv.UpgradeMessage = fmt.Sprintf("Now you can use %s to upgrade component %s to the latest version", color.SGreen("%s upgrade %s", os.Args[0], name), name)
+		cmv.UpgradeMessage = fmt.Sprintf("Now you can use %s upgrade %s to upgrade component %s to the latest version", os.Args[0], name, name)
 	}
 	opt.log.Debugf("local: %s(%s), remote : %s(%s), upgrade: %v", localcv, localav, remotecv, remoteav, cmv.CanUpgrade)
 	return cmv, err
@@ -93,7 +92,10 @@ func (opt *Option) fetchCR(ns, name string) (string, string, error) {
 }
 
 func Upgrade(flagVersion string, log log.Logger) error {
-	helmClient, _ := helm.NewClient(&helm.Config{Namespace: common.GetDefaultSystemNamespace(true)})
+	helmClient, err := helm.NewClient(&helm.Config{Namespace: common.GetDefaultSystemNamespace(true), Wait: true, Progress: log.Debugf})
+	if err != nil {
+		return errors.Errorf("create helm client err: %v", err)
+	}
 	if err := helmClient.UpdateRepo(); err != nil {
 		log.Errorf("update repo failed, reason: %v", err)
 		return err
@@ -0,0 +1,191 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package quickon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	qcexec "github.com/easysoft/qcadmin/internal/pkg/util/exec"
+	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
+	"github.com/easysoft/qcadmin/internal/pkg/util/httptls"
+	"github.com/easysoft/qcadmin/internal/pkg/util/kutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SetDomain 切换渠成域名, 更新 qucheng 及 cne-operator, 可选改写应用 ingress
+func (m *Meta) SetDomain(rewriteApps bool) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	oldDomain := cfg.Domain
+	if len(m.Domain) == 0 {
+		return errors.New("missing new domain")
+	}
+	if m.Domain == oldDomain {
+		return errors.Errorf("domain is already %s", m.Domain)
+	}
+	ctx := context.Background()
+	tlsCfg, err := m.domainTLS(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	hc, err := m.helmClient()
+	if err != nil {
+		return errors.Wrap(err, "create helm client failed")
+	}
+	host := m.Domain
+	if !kutil.IsLegalDomain(host) {
+		host = fmt.Sprintf("console.%s", host)
+	}
+	sets := []string{"env.APP_DOMAIN=" + m.Domain, "ingress.host=" + host}
+	if len(tlsCfg.SecretName) > 0 {
+		sets = append(sets, "ingress.tls.enabled=true", "ingress.tls.secretName="+tlsCfg.SecretName)
+	} else {
+		sets = append(sets, "ingress.tls.enabled=false")
+	}
+	if err := m.upgradeKeepValues(hc, common.DefaultQuchengName, sets); err != nil {
+		return errors.Wrapf(err, "upgrade %s failed", common.DefaultQuchengName)
+	}
+	m.log.Donef("upgrade %s with domain %s success", common.DefaultQuchengName, m.Domain)
+	if err := m.upgradeKeepValues(hc, common.DefaultCneOperatorName, []string{"minio.ingress.host=s3." + m.Domain}); err != nil {
+		m.log.Warnf("upgrade %s failed, reason: %v", common.DefaultCneOperatorName, err)
+	} else {
+		m.log.Donef("upgrade %s with domain %s success", common.DefaultCneOperatorName, m.Domain)
+	}
+	if rewriteApps {
+		if err := m.rewriteAppIngress(ctx, oldDomain); err != nil {
+			m.log.Warnf("rewrite app ingress failed, reason: %v", err)
+		}
+	}
+	// 清理旧的托管域名解析, 需在保存新域名前执行
+	if kutil.IsLegalDomain(oldDomain) {
+		if err := qcexec.Command(os.Args[0], "experimental", "tools", "domain", "clean").Run(); err != nil {
+			m.log.Warnf("clean old domain %s failed, reason: %v", oldDomain, err)
+		} else {
+			m.log.Donef("clean old domain %s success", oldDomain)
+		}
+	}
+	cfg.Domain = m.Domain
+	cfg.TLS = tlsCfg
+	if err := cfg.SaveConfig(); err != nil {
+		return err
+	}
+	if !kutil.IsLegalDomain(m.Domain) {
+		m.log.Infof("you should add dns record to your domain: *.%s -> %s", m.Domain, cfg.Cluster.InitNode)
+	}
+	return nil
+}
+
+// domainTLS 按新域名准备证书, 无法沿用旧证书时关闭 tls
+func (m *Meta) domainTLS(ctx context.Context, cfg *config.Config) (config.TLS, error) {
+	kp, err := m.loadCustomTLS()
+	if err != nil {
+		return config.TLS{}, err
+	}
+	switch {
+	case kp != nil:
+		if err := kp.ApplySecret(ctx, m.kubeClient, common.CustomTLSSecretName); err != nil {
+			return config.TLS{}, err
+		}
+		m.log.Donef("load custom tls cert for %s success", m.Domain)
+		return config.TLS{Type: common.TLSTypeCustom, SecretName: common.CustomTLSSecretName}, nil
+	case kutil.IsLegalDomain(m.Domain):
+		defaultTLS := fmt.Sprintf("%s/hack/haogstls/haogs.yaml", common.GetDefaultDataDir())
+		for _, ns := range []string{common.GetDefaultSystemNamespace(true), "default"} {
+			if err := qcexec.Command(os.Args[0], "experimental", "kubectl", "apply", "-f", defaultTLS, "-n", ns, "--kubeconfig", common.GetKubeConfig()).Run(); err != nil {
+				m.log.Warnf("load tls cert for %s failed, reason: %v", ns, err)
+			}
+		}
+		return config.TLS{Type: common.TLSTypeHaogs, SecretName: common.DefaultTLSSecretName}, nil
+	case cfg.TLS.Type == common.TLSTypeSelfSigned:
+		if err := m.applySelfSignedTLS(ctx); err != nil {
+			return config.TLS{}, err
+		}
+		return cfg.TLS, nil
+	case cfg.TLS.Type == common.TLSTypeACME && cfg.TLS.ACME != nil:
+		opts := httptls.ACMEOptions{Solver: cfg.TLS.ACME.Solver}
+		if err := httptls.ApplyCertificate(ctx, m.kubeClient, opts.DNSNames(m.Domain)); err != nil {
+			return config.TLS{}, err
+		}
+		m.log.Infof("certificate for %s will be issued by cert-manager, check it and sync to default namespace by: q manage renewtls", m.Domain)
+		return cfg.TLS, nil
+	}
+	if len(cfg.TLS.SecretName) > 0 {
+		m.log.Warnf("tls cert of %s does not match %s, disable tls, set it later by: q manage tls set", cfg.Domain, m.Domain)
+	}
+	return config.TLS{}, nil
+}
+
+// upgradeKeepValues 使用当前 chart 版本升级, 保留已有配置
+func (m *Meta) upgradeKeepValues(hc *helm.Client, name string, sets []string) error {
+	values, err := hc.GetValues(name)
+	if err != nil {
+		return err
+	}
+	newValues, err := helm.MergeValues(sets)
+	if err != nil {
+		return err
+	}
+	rel, err := hc.GetDetail(name)
+	if err != nil {
+		return err
+	}
+	_, err = hc.Upgrade(name, common.DefaultHelmRepoName, rel.Chart.Metadata.Name, rel.Chart.Metadata.Version, helm.MergeMaps(values, newValues))
+	return err
+}
+
+// rewriteAppIngress 将应用 ingress 中旧域名替换为新域名, 应用升级时以渠成下发的配置为准
+func (m *Meta) rewriteAppIngress(ctx context.Context, oldDomain string) error {
+	ingresses, err := m.kubeClient.ListIngresses(ctx, common.DefaultAppNamespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range ingresses.Items {
+		ing := &ingresses.Items[i]
+		changed := false
+		for j, rule := range ing.Spec.Rules {
+			if h, ok := replaceDomain(rule.Host, oldDomain, m.Domain); ok {
+				ing.Spec.Rules[j].Host = h
+				changed = true
+			}
+		}
+		for j, t := range ing.Spec.TLS {
+			for k, host := range t.Hosts {
+				if h, ok := replaceDomain(host, oldDomain, m.Domain); ok {
+					ing.Spec.TLS[j].Hosts[k] = h
+				}
+			}
+		}
+		if !changed {
+			continue
+		}
+		if _, err := m.kubeClient.UpdateIngress(ctx, ing, metav1.UpdateOptions{}); err != nil {
+			m.log.Warnf("rewrite ingress %s failed, reason: %v", ing.Name, err)
+			continue
+		}
+		m.log.Donef("rewrite ingress %s success", ing.Name)
+	}
+	return nil
+}
+
+// replaceDomain app.old.com -> app.new.com
+func replaceDomain(host, oldDomain, newDomain string) (string, bool) {
+	if host == oldDomain {
+		return newDomain, true
+	}
+	if strings.HasSuffix(host, "."+oldDomain) {
+		return strings.TrimSuffix(host, oldDomain) + newDomain, true
+	}
+	return host, false
+}
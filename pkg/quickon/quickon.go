@@ -21,8 +21,10 @@ import (
 	"github.com/easysoft/qcadmin/internal/pkg/k8s"
 	qcexec "github.com/easysoft/qcadmin/internal/pkg/util/exec"
 	"github.com/easysoft/qcadmin/internal/pkg/util/factory"
+	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
 	"github.com/easysoft/qcadmin/internal/pkg/util/kutil"
 	"github.com/easysoft/qcadmin/internal/pkg/util/log"
+	"github.com/easysoft/qcadmin/internal/pkg/util/proxy"
 	"github.com/easysoft/qcadmin/internal/pkg/util/retry"
 	suffixdomain "github.com/easysoft/qcadmin/pkg/qucheng/domain"
 	"github.com/ergoapi/util/color"
@@ -36,6 +38,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// helmTimeout 等待渠成组件就绪的超时时间, 首次部署需拉取镜像
+const helmTimeout = 10 * time.Minute
+
 type Meta struct {
 	Domain          string
 	IP              string
@@ -44,6 +49,9 @@ type Meta struct {
 	OffLine         bool
 	QuickonOSS      bool
 	QuickonType     common.QuickonType
+	TLSCert         string
+	TLSKey          string
+	Proxy           config.Proxy
 	kubeClient      *k8s.Client
 	log             log.Logger
 }
@@ -58,7 +66,7 @@ func New(f factory.Factory) *Meta {
 }
 
 func (m *Meta) GetFlags() []types.Flag {
-	return []types.Flag{
+	fs := []types.Flag{
 		{
 			Name:  "domain",
 			Usage: "quickon domain",
@@ -96,7 +104,29 @@ func (m *Meta) GetFlags() []types.Flag {
 			P:     &m.OffLine,
 			V:     false,
 		},
+		{
+			Name:  "tls-cert",
+			Usage: "tls cert file of custom domain, should match *.domain",
+			P:     &m.TLSCert,
+			V:     m.TLSCert,
+		},
+		{
+			Name:  "tls-key",
+			Usage: "tls key file of custom domain",
+			P:     &m.TLSKey,
+			V:     m.TLSKey,
+		},
 	}
+	return append(fs, proxy.Flags(&m.Proxy)...)
+}
+
+// SetProxyEnv 导出代理环境变量, 需在发起请求前调用
+func (m *Meta) SetProxyEnv() {
+	cfg, _ := config.LoadConfig()
+	if m.Proxy.Enabled() {
+		cfg.Proxy = m.Proxy
+	}
+	proxy.Setenv(cfg, exnet.LocalIPs()...)
 }
 
 func (m *Meta) GetKubeClient() error {
@@ -177,30 +207,63 @@ func (m *Meta) initNS() error {
 	return nil
 }
 
+// helmClient 等待资源就绪, 部署失败时返回 release 状态及未就绪的资源
+func (m *Meta) helmClient() (*helm.Client, error) {
+	return helm.NewClient(&helm.Config{Namespace: common.GetDefaultSystemNamespace(true), Wait: true, Timeout: helmTimeout, Progress: m.log.Debugf})
+}
+
+// hubAddress 离线 hub 地址, 未初始化 hub 时使用本机 ip
+func hubAddress() string {
+	cfg, _ := config.LoadConfig()
+	if cfg != nil && len(cfg.Hub.Address) > 0 {
+		return cfg.Hub.Address
+	}
+	return exnet.LocalIPs()[0]
+}
+
 func (m *Meta) addHelmRepo() error {
-	output, err := qcexec.Command(os.Args[0], "experimental", "helm", "repo-add", "--name", common.DefaultHelmRepoName, "--url", common.GetChartRepo(m.Version)).CombinedOutput()
+	hc, err := m.helmClient()
 	if err != nil {
-		errmsg := string(output)
-		if !strings.Contains(errmsg, "exists") {
-			m.log.Errorf("init quickon helm repo failed, reason: %s", string(output))
+		return errors.Wrap(err, "create helm client failed")
+	}
+	repoURL := common.GetChartRepo(m.Version)
+	if m.OffLine {
+		repoURL = common.GetOfflineChartRepo()
+	}
+	if err := hc.AddRepo(common.DefaultHelmRepoName, repoURL, "", ""); err != nil {
+		if !strings.Contains(err.Error(), "exists") {
+			m.log.Errorf("init quickon helm repo failed, reason: %v", err)
 			return err
 		}
 		m.log.Debugf("quickon helm repo already exists")
 	} else {
 		m.log.Done("add quickon helm repo success")
 	}
-	output, err = qcexec.Command(os.Args[0], "experimental", "helm", "repo-update").CombinedOutput()
-	if err != nil {
-		m.log.Errorf("update quickon helm repo failed, reason: %s", string(output))
+	if err := hc.UpdateRepo(); err != nil {
+		m.log.Errorf("update quickon helm repo failed, reason: %v", err)
 		return err
 	}
 	m.log.Done("update quickon helm repo success")
 	return nil
 }
 
+// upgradeRelease 安装或升级 chart, values 格式同 --set
+func (m *Meta) upgradeRelease(hc *helm.Client, name, chart, version string, sets []string) error {
+	values, err := helm.MergeValues(sets)
+	if err != nil {
+		return err
+	}
+	_, err = hc.Upgrade(name, common.DefaultHelmRepoName, chart, version, values)
+	return err
+}
+
 func (m *Meta) Init() error {
 	m.log.Info("executing init quickon logic...")
 	ctx := context.Background()
+	customTLS, err := m.loadCustomTLS()
+	if err != nil {
+		return err
+	}
 	m.log.Debug("waiting for storage to be ready...")
 	waitsc := time.Now()
 	// wait.BackoffUntil TODO
@@ -218,7 +281,7 @@ func (m *Meta) Init() error {
 		}
 	}
 
-	_, err := m.kubeClient.CreateNamespace(ctx, common.GetDefaultSystemNamespace(true), metav1.CreateOptions{})
+	_, err = m.kubeClient.CreateNamespace(ctx, common.GetDefaultSystemNamespace(true), metav1.CreateOptions{})
 	if err != nil {
 		if !kubeerr.IsAlreadyExists(err) {
 			return err
@@ -240,7 +303,9 @@ func (m *Meta) Init() error {
 			m.Domain = "demo.corp.cc"
 			m.log.Warnf("gen suffix domain failed, reason: %v, use default domain: %s", err, m.Domain)
 		}
-		if kutil.IsLegalDomain(m.Domain) {
+		if m.OffLine {
+			m.log.Infof("offline mode, skip issuing domain %s certificate from remote", m.Domain)
+		} else if kutil.IsLegalDomain(m.Domain) {
 			m.log.Infof("load %s tls cert", m.Domain)
 			defaultTLS := fmt.Sprintf("%s/tls-haogs-cn.yaml", common.GetDefaultCacheDir())
 			m.log.StartWait(fmt.Sprintf("start issuing domain %s certificate, may take 3-5min", m.Domain))
@@ -276,65 +341,91 @@ func (m *Meta) Init() error {
 		m.log.Infof("use custom domain %s, you should add dns record to your domain: *.%s -> %s", m.Domain, color.SGreen(m.Domain), color.SGreen(m.IP))
 	}
 	token := expass.PwGenAlphaNum(32)
-	cfg, _ := config.LoadConfig()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
 	cfg.Domain = m.Domain
 	cfg.APIToken = token
 	cfg.S3.Username = expass.PwGenAlphaNum(8)
 	cfg.S3.Password = expass.PwGenAlphaNum(16)
 	cfg.Quickon.Type = m.QuickonType
-	cfg.SaveConfig()
+	if m.Proxy.Enabled() {
+		cfg.Proxy = m.Proxy
+	}
+	// 重新初始化时不沿用旧证书配置, 对应的 secret 可能已不存在
+	cfg.TLS = config.TLS{}
+	switch {
+	case customTLS != nil:
+		if err := customTLS.ApplySecret(ctx, m.kubeClient, common.CustomTLSSecretName); err != nil {
+			return err
+		}
+		m.log.Donef("load custom tls cert for %s success", m.Domain)
+		cfg.TLS.Type = common.TLSTypeCustom
+		cfg.TLS.SecretName = common.CustomTLSSecretName
+	case m.OffLine:
+		if err := m.applySelfSignedTLS(ctx); err != nil {
+			return err
+		}
+		cfg.TLS.Type = common.TLSTypeSelfSigned
+		cfg.TLS.SecretName = common.SelfSignedTLSSecretName
+	case kutil.IsLegalDomain(m.Domain):
+		cfg.TLS.Type = common.TLSTypeHaogs
+		cfg.TLS.SecretName = common.DefaultTLSSecretName
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		return err
+	}
+	hc, err := m.helmClient()
+	if err != nil {
+		return errors.Wrap(err, "create helm client failed")
+	}
 	m.log.Info("start deploy cne custom tools")
-	toolargs := []string{"experimental", "helm", "upgrade", "--name", "selfcert", "--repo", common.DefaultHelmRepoName, "--chart", "selfcert", "--namespace", common.GetDefaultSystemNamespace(true)}
-	if helmstd, err := qcexec.Command(os.Args[0], toolargs...).CombinedOutput(); err != nil {
-		m.log.Warnf("deploy cne custom tools err: %v, std: %s", err, string(helmstd))
+	if err := m.upgradeRelease(hc, "selfcert", "selfcert", "", nil); err != nil {
+		m.log.Warnf("deploy cne custom tools err: %v", err)
 	} else {
 		m.log.Done("deployed cne custom tools success")
 	}
 	m.log.Info("start deploy cne operator")
-	operatorargs := []string{"experimental", "helm", "upgrade", "--name", common.DefaultCneOperatorName, "--repo", common.DefaultHelmRepoName, "--chart", common.DefaultCneOperatorName, "--namespace", common.GetDefaultSystemNamespace(true),
-		"--set", "minio.ingress.enabled=true",
-		"--set", "minio.ingress.host=s3." + m.Domain,
-		"--set", "minio.auth.username=" + cfg.S3.Username,
-		"--set", "minio.auth.password=" + cfg.S3.Password,
-	}
-	//if len(chartversion) > 0 {
-	//	operatorargs = append(operatorargs, "--version", chartversion)
-	//}
-	if helmstd, err := qcexec.Command(os.Args[0], operatorargs...).CombinedOutput(); err != nil {
-		m.log.Warnf("deploy cne-operator err: %v, std: %s", err, string(helmstd))
+	operatorargs := []string{
+		"minio.ingress.enabled=true",
+		"minio.ingress.host=s3." + m.Domain,
+		"minio.auth.username=" + cfg.S3.Username,
+		"minio.auth.password=" + cfg.S3.Password,
+	}
+	if err := m.upgradeRelease(hc, common.DefaultCneOperatorName, common.DefaultCneOperatorName, "", operatorargs); err != nil {
+		m.log.Warnf("deploy cne-operator err: %v", err)
 	} else {
 		m.log.Done("deployed cne-operator success")
 	}
 	helmchan := common.GetChannel(m.Version)
-	helmargs := []string{"experimental", "helm", "upgrade", "--name", common.DefaultQuchengName, "--repo", common.DefaultHelmRepoName, "--chart", common.GetQuickONName(m.QuickonType), "--namespace", common.GetDefaultSystemNamespace(true), "--set", "env.APP_DOMAIN=" + m.Domain, "--set", "env.CNE_API_TOKEN=" + token, "--set", "cloud.defaultChannel=" + helmchan}
+	helmargs := []string{"env.APP_DOMAIN=" + m.Domain, "env.CNE_API_TOKEN=" + token, "cloud.defaultChannel=" + helmchan}
 	if helmchan != "stable" {
-		helmargs = append(helmargs, "--set", "env.PHP_DEBUG=2")
-		helmargs = append(helmargs, "--set", "cloud.switchChannel=true")
-		helmargs = append(helmargs, "--set", "cloud.selectVersion=true")
+		helmargs = append(helmargs, "env.PHP_DEBUG=2")
+		helmargs = append(helmargs, "cloud.switchChannel=true")
+		helmargs = append(helmargs, "cloud.selectVersion=true")
 	}
 	hostdomain := m.Domain
-	if kutil.IsLegalDomain(hostdomain) {
-		helmargs = append(helmargs, "--set", "ingress.tls.enabled=true")
-		helmargs = append(helmargs, "--set", "ingress.tls.secretName=tls-haogs-cn")
-	} else {
+	if !kutil.IsLegalDomain(hostdomain) {
 		hostdomain = fmt.Sprintf("console.%s", hostdomain)
 	}
+	if len(cfg.TLS.SecretName) > 0 {
+		helmargs = append(helmargs, "ingress.tls.enabled=true")
+		helmargs = append(helmargs, "ingress.tls.secretName="+cfg.TLS.SecretName)
+	}
 
 	if m.OffLine {
-		helmargs = append(helmargs, "--set", fmt.Sprintf("cloud.host=http://market-cne-market-api.quickon-system.svc:8088"))
-		helmargs = append(helmargs, "--set", fmt.Sprintf("env.CNE_MARKET_API_SCHEMA=http"))
-		helmargs = append(helmargs, "--set", fmt.Sprintf("env.CNE_MARKET_API_HOST=market-cne-market-api.quickon-system.svc"))
-		helmargs = append(helmargs, "--set", fmt.Sprintf("env.CNE_MARKET_API_PORT=8088"))
+		helmargs = append(helmargs, "cloud.host=http://market-cne-market-api.quickon-system.svc:8088")
+		helmargs = append(helmargs, "env.CNE_MARKET_API_SCHEMA=http")
+		helmargs = append(helmargs, "env.CNE_MARKET_API_HOST=market-cne-market-api.quickon-system.svc")
+		helmargs = append(helmargs, "env.CNE_MARKET_API_PORT=8088")
 	}
 
-	helmargs = append(helmargs, "--set", fmt.Sprintf("ingress.host=%s", hostdomain))
+	helmargs = append(helmargs, fmt.Sprintf("ingress.host=%s", hostdomain))
+	helmargs = append(helmargs, proxy.HelmValues(cfg)...)
 
-	if len(chartVersion) > 0 {
-		helmargs = append(helmargs, "--version", chartVersion)
-	}
-	output, err := qcexec.Command(os.Args[0], helmargs...).CombinedOutput()
-	if err != nil {
-		m.log.Errorf("upgrade install quickon web failed: %s", string(output))
+	if err := m.upgradeRelease(hc, common.DefaultQuchengName, common.GetQuickONName(m.QuickonType), chartVersion, helmargs); err != nil {
+		m.log.Errorf("upgrade install quickon web failed: %v", err)
 		return err
 	}
 	m.log.Done("install quickon success")
@@ -356,9 +447,9 @@ repositories:
   name: qucheng-stable
   pass_credentials_all: false
   password: ""
-  url: http://%s:32377
+  url: http://%s:%d
   username: ""
-`, exnet.LocalIPs()[0])
+`, hubAddress(), common.HubAppPort)
 				_, err := m.kubeClient.UpdateConfigMap(ctx, foundRepofiles, metav1.UpdateOptions{})
 				if err != nil {
 					m.log.Warnf("patch offline repo file, check: kubectl get cm/%s  -n %s", cmfileName, common.GetDefaultSystemNamespace(true))
@@ -379,10 +470,8 @@ repositories:
 
 		// install cne-market
 		m.log.Infof("start deploy cne cne-market")
-		marketargs := []string{"experimental", "helm", "upgrade", "--name", "market", "--repo", common.DefaultHelmRepoName, "--chart", "cne-market-api", "--namespace", common.GetDefaultSystemNamespace(true)}
-		output, err := qcexec.Command(os.Args[0], marketargs...).CombinedOutput()
-		if err != nil {
-			m.log.Warnf("upgrade install quickon market failed: %s", string(output))
+		if err := m.upgradeRelease(hc, "market", "cne-market-api", "", nil); err != nil {
+			m.log.Warnf("upgrade install quickon market failed: %v", err)
 		}
 	}
 	m.QuickONReady()
@@ -457,14 +546,22 @@ func (m *Meta) Show() {
 	}
 	resetPassArgs := []string{"quickon", "reset-password", "--password", m.ConsolePassword}
 	qcexec.CommandRun(os.Args[0], resetPassArgs...)
-	cfg, _ := config.LoadConfig()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		m.log.Warnf("load config failed, reason: %v", err)
+	}
 	cfg.ConsolePassword = m.ConsolePassword
-	cfg.SaveConfig()
+	if err := cfg.SaveConfig(); err != nil {
+		m.log.Warnf("save console password failed, reason: %v", err)
+	}
 	domain := cfg.Domain
 
 	m.log.Info("----------------------------\t")
 	if len(domain) > 0 {
-		if !kutil.IsLegalDomain(cfg.Domain) {
+		httpsConsole := cfg.TLS.Type == common.TLSTypeCustom || cfg.TLS.Type == common.TLSTypeACME || cfg.TLS.Type == common.TLSTypeSelfSigned
+		if httpsConsole && !kutil.IsLegalDomain(cfg.Domain) {
+			domain = fmt.Sprintf("https://console.%s", cfg.Domain)
+		} else if !kutil.IsLegalDomain(cfg.Domain) {
 			domain = fmt.Sprintf("http://console.%s", cfg.Domain)
 		} else {
 			domain = fmt.Sprintf("https://%s", cfg.Domain)
@@ -474,6 +571,9 @@ func (m *Meta) Show() {
 	}
 	m.log.Donef("console: %s, username: %s, password: %s",
 		color.SGreen(domain), color.SGreen(common.QuchengDefaultUser), color.SGreen(m.ConsolePassword))
+	if cfg.TLS.Type == common.TLSTypeSelfSigned {
+		m.log.Infof("console uses self-signed cert, export ca by: %s manage tls ca", os.Args[0])
+	}
 	m.log.Donef("docs: %s", common.QuchengDocs)
 	m.log.Done("support: 768721743(QQGroup)")
 }
@@ -482,30 +582,28 @@ func (m *Meta) UnInstall() error {
 	m.log.Warnf("start clean quickon.")
 	cfg, _ := config.LoadConfig()
 	// 清理helm安装应用
-	m.log.Info("start uninstall cne custom tools")
-	toolArgs := []string{"experimental", "helm", "uninstall", "--name", "selfcert", "--namespace", common.GetDefaultSystemNamespace(true)}
-	if cleanStd, err := qcexec.Command(os.Args[0], toolArgs...).CombinedOutput(); err != nil {
-		m.log.Warnf("uninstall cne custom tools err: %v, std: %s", err, string(cleanStd))
-	} else {
-		m.log.Done("uninstall cne custom tools success")
-	}
-	m.log.Info("start uninstall cne operator")
-	operatorArgs := []string{"experimental", "helm", "uninstall", "--name", common.DefaultCneOperatorName, "--namespace", common.GetDefaultSystemNamespace(true)}
-	if cleanStd, err := qcexec.Command(os.Args[0], operatorArgs...).CombinedOutput(); err != nil {
-		m.log.Warnf("uninstall cne-operator err: %v, std: %s", err, string(cleanStd))
-	} else {
-		m.log.Done("uninstall cne-operator success")
-	}
-	m.log.Info("start uninstall cne quickon")
-	quickonCleanArgs := []string{"experimental", "helm", "uninstall", "--name", common.DefaultQuchengName, "--namespace", common.GetDefaultSystemNamespace(true)}
-	if cleanStd, err := qcexec.Command(os.Args[0], quickonCleanArgs...).CombinedOutput(); err != nil {
-		m.log.Warnf("uninstall quickon err: %v, std: %s", err, string(cleanStd))
-	} else {
-		m.log.Done("uninstall quickon success")
+	hc, err := m.helmClient()
+	if err != nil {
+		return errors.Wrap(err, "create helm client failed")
+	}
+	for _, c := range []struct{ release, desc string }{
+		{"selfcert", "cne custom tools"},
+		{common.DefaultCneOperatorName, "cne-operator"},
+		{common.DefaultQuchengName, "quickon"},
+	} {
+		m.log.Infof("start uninstall %s", c.desc)
+		if rel, _ := hc.GetDetail(c.release); rel == nil {
+			m.log.Donef("uninstall %s success", c.desc)
+			continue
+		}
+		if _, err := hc.Uninstall(c.release); err != nil {
+			m.log.Warnf("uninstall %s err: %v", c.desc, err)
+		} else {
+			m.log.Donef("uninstall %s success", c.desc)
+		}
 	}
 	m.log.Info("start uninstall helm repo")
-	repoCleanArgs := []string{"experimental", "helm", "repo-del"}
-	_ = qcexec.Command(os.Args[0], repoCleanArgs...).Run()
+	_ = hc.RemoveRepo(common.DefaultHelmRepoName)
 	m.log.Done("uninstall helm repo success")
 	if strings.HasSuffix(cfg.Domain, "haogs.cn") || strings.HasSuffix(cfg.Domain, "corp.cc") {
 		m.log.Infof("clean domain %s", cfg.Domain)
@@ -0,0 +1,194 @@
+// Copyright (c) 2021-2023 北京渠成软件有限公司(Beijing Qucheng Software Co., Ltd. www.qucheng.com) All rights reserved.
+// Use of this source code is covered by the following dual licenses:
+// (1) Z PUBLIC LICENSE 1.2 (ZPL 1.2)
+// (2) Affero General Public License 3.0 (AGPL 3.0)
+// license that can be found in the LICENSE file.
+
+package quickon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/easysoft/qcadmin/common"
+	"github.com/easysoft/qcadmin/internal/app/config"
+	"github.com/easysoft/qcadmin/internal/pkg/plugin"
+	"github.com/easysoft/qcadmin/internal/pkg/util/helm"
+	"github.com/easysoft/qcadmin/internal/pkg/util/httptls"
+	"github.com/easysoft/qcadmin/internal/pkg/util/kutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// loadCustomTLS 校验自定义域名证书, 未指定证书时返回 nil
+func (m *Meta) loadCustomTLS() (*httptls.KeyPair, error) {
+	if len(m.TLSCert) == 0 && len(m.TLSKey) == 0 {
+		return nil, nil
+	}
+	if len(m.TLSCert) == 0 || len(m.TLSKey) == 0 {
+		return nil, errors.New("both --tls-cert and --tls-key are required")
+	}
+	if len(m.Domain) == 0 || kutil.IsLegalDomain(m.Domain) {
+		return nil, errors.New("custom tls cert requires a custom --domain")
+	}
+	kp, err := httptls.LoadKeyPair(m.TLSCert, m.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := kp.ValidateDomain(m.Domain); err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+// SetTLS 为已安装的渠成更新自定义域名证书
+func (m *Meta) SetTLS() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if len(m.Domain) == 0 {
+		m.Domain = cfg.Domain
+	}
+	if m.Domain != cfg.Domain {
+		return errors.Errorf("domain %s does not match current domain %s", m.Domain, cfg.Domain)
+	}
+	kp, err := m.loadCustomTLS()
+	if err != nil {
+		return err
+	}
+	if kp == nil {
+		return errors.New("both --tls-cert and --tls-key are required")
+	}
+	ctx := context.Background()
+	if err := kp.ApplySecret(ctx, m.kubeClient, common.CustomTLSSecretName); err != nil {
+		return err
+	}
+	m.log.Donef("load custom tls cert for %s success, expire at %s", m.Domain, kp.Leaf.NotAfter.Format("2006-01-02"))
+	if err := m.upgradeIngressTLS(common.CustomTLSSecretName); err != nil {
+		return err
+	}
+	cfg.TLS.Type = common.TLSTypeCustom
+	cfg.TLS.SecretName = common.CustomTLSSecretName
+	return cfg.SaveConfig()
+}
+
+// applySelfSignedTLS 离线安装时使用本地 CA 签发 *.domain 证书
+func (m *Meta) applySelfSignedTLS(ctx context.Context) error {
+	ca, err := httptls.LoadOrCreateCA()
+	if err != nil {
+		return errors.Wrap(err, "load offline ca failed")
+	}
+	kp, err := ca.IssueServer(m.Domain)
+	if err != nil {
+		return err
+	}
+	if err := kp.ApplySecret(ctx, m.kubeClient, common.SelfSignedTLSSecretName); err != nil {
+		return err
+	}
+	m.log.Donef("issue self-signed tls cert for %s success, ca: %s", m.Domain, httptls.CAFile())
+	return nil
+}
+
+// SetACME 通过 cert-manager 为自定义域名自动签发证书
+func (m *Meta) SetACME(opts httptls.ACMEOptions, wait time.Duration) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Domain) == 0 || kutil.IsLegalDomain(cfg.Domain) {
+		return errors.New("acme cert requires a custom domain")
+	}
+	ctx := context.Background()
+	if opts.Solver == httptls.SolverHTTP01 && len(opts.IngressClass) == 0 {
+		if ic, err := m.kubeClient.ListDefaultIngressClass(ctx, metav1.ListOptions{}); err == nil {
+			opts.IngressClass = ic.Name
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	cm, err := plugin.GetMeta("cert-manager")
+	if err != nil {
+		return err
+	}
+	cm.Client = m.kubeClient
+	cm.Values = map[string]interface{}{"installCRDs": true}
+	if err := cm.Install(); err != nil {
+		return err
+	}
+	// webhook 就绪前创建 ClusterIssuer 会被拒绝
+	m.log.StartWait("create acme cluster issuer")
+	deadline := time.Now().Add(2 * time.Minute)
+	for {
+		err = opts.ApplyClusterIssuer(ctx, m.kubeClient)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		m.log.Debugf("apply cluster issuer failed, retry: %v", err)
+		time.Sleep(5 * time.Second)
+	}
+	m.log.StopWait()
+	if err != nil {
+		return err
+	}
+	m.log.Donef("create cluster issuer %s success, server: %s", common.ACMEIssuerName, opts.Server)
+	dnsNames := opts.DNSNames(cfg.Domain)
+	if opts.Solver == httptls.SolverHTTP01 {
+		m.log.Warnf("http01 solver can not issue wildcard cert, only %s are covered, use --solver dns01 for apps under *.%s", strings.Join(dnsNames, ","), cfg.Domain)
+	}
+	if err := httptls.ApplyCertificate(ctx, m.kubeClient, dnsNames); err != nil {
+		return err
+	}
+	if wait > 0 {
+		m.log.StartWait(fmt.Sprintf("wait certificate of %s ready", cfg.Domain))
+		cs, err := httptls.WaitCertificateReady(ctx, m.kubeClient, common.GetDefaultSystemNamespace(true), wait)
+		m.log.StopWait()
+		if err != nil {
+			return err
+		}
+		m.log.Donef("issue certificate for %s success, expire at %s", cfg.Domain, cs.NotAfter)
+		if err := httptls.SyncACMESecret(ctx, m.kubeClient); err != nil {
+			return err
+		}
+	} else {
+		m.log.Infof("tls secret of default namespace will be synced after issued by: q manage renewtls")
+	}
+	if err := m.upgradeIngressTLS(common.ACMETLSSecretName); err != nil {
+		return err
+	}
+	cfg.TLS.Type = common.TLSTypeACME
+	cfg.TLS.SecretName = common.ACMETLSSecretName
+	cfg.TLS.ACME = &config.ACME{
+		Email:       opts.Email,
+		Server:      opts.Server,
+		Solver:      opts.Solver,
+		DNSProvider: opts.DNSProvider,
+	}
+	return cfg.SaveConfig()
+}
+
+// upgradeIngressTLS 更新渠成控制台 ingress 证书, 保留已有配置
+func (m *Meta) upgradeIngressTLS(secretName string) error {
+	hc, err := m.helmClient()
+	if err != nil {
+		return errors.Wrap(err, "create helm client failed")
+	}
+	values, err := hc.GetValues(common.DefaultQuchengName)
+	if err != nil {
+		return err
+	}
+	tlsValues, _ := helm.MergeValues([]string{"ingress.tls.enabled=true", fmt.Sprintf("ingress.tls.secretName=%s", secretName)})
+	values = helm.MergeMaps(values, tlsValues)
+	rel, err := hc.GetDetail(common.DefaultQuchengName)
+	if err != nil {
+		return err
+	}
+	if _, err := hc.Upgrade(common.DefaultQuchengName, common.DefaultHelmRepoName, rel.Chart.Metadata.Name, rel.Chart.Metadata.Version, values); err != nil {
+		return err
+	}
+	m.log.Donef("upgrade %s ingress tls success", common.DefaultQuchengName)
+	return nil
+}